@@ -0,0 +1,4 @@
+// Package usersv1 holds the protobuf and gRPC definitions of the user API.
+package usersv1
+
+//go:generate protoc -I ../.. --go_out=../.. --go_opt=paths=source_relative --go-grpc_out=../.. --go-grpc_opt=paths=source_relative users/v1/users.proto
@@ -0,0 +1,15 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"practice4/practice-4/internal/usersctl"
+)
+
+func main() {
+	log.SetFlags(0)
+	if err := usersctl.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,48 @@
+package database
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+)
+
+// Migrations holds the SQL migrations compiled into the binary, so the schema
+// travels with it regardless of the working directory.
+//
+//go:embed migrations/*.sql
+var Migrations embed.FS
+
+type Migration struct {
+	Version uint
+	Name    string
+	SQL     string
+}
+
+// UpMigrations returns the up migrations at the root of fsys ordered by version.
+func UpMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []Migration
+	for _, e := range entries {
+		match := migrationFile.FindStringSubmatch(e.Name())
+		if match == nil || match[3] != "up" {
+			continue
+		}
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", e.Name(), err)
+		}
+		data, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{Version: uint(version), Name: match[2], SQL: string(data)})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DestructiveMarker must appear in an up migration that intentionally drops a
+// table or column.
+const DestructiveMarker = "-- migrate:destructive"
+
+var (
+	migrationFile = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+	lineComment   = regexp.MustCompile(`--[^\n]*`)
+	dropTable     = regexp.MustCompile(`(?i)\bDROP\s+TABLE\b`)
+	alterTable    = regexp.MustCompile(`(?i)^\s*ALTER\s+TABLE\b`)
+	alterDrop     = regexp.MustCompile(`(?i)\bDROP\s+(\w+)`)
+
+	// keptDrops follow DROP in ALTER TABLE actions that lose no data, such
+	// as DROP CONSTRAINT or ALTER COLUMN ... DROP NOT NULL. Any other word
+	// starts a column drop, whose COLUMN keyword is optional.
+	keptDrops = map[string]bool{"CONSTRAINT": true, "DEFAULT": true, "NOT": true, "EXPRESSION": true, "IDENTITY": true}
+)
+
+// Lint checks the migrations at the root of fsys: every up migration needs a
+// down migration of the same name and vice versa, and up migrations may only
+// drop tables or columns when they carry DestructiveMarker.
+func Lint(fsys fs.FS) error {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	pairs := make(map[string]map[string]bool)
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".sql") {
+			continue
+		}
+		match := migrationFile.FindStringSubmatch(e.Name())
+		if match == nil {
+			errs = append(errs, fmt.Errorf("%s: name must look like 000001_name.up.sql or 000001_name.down.sql", e.Name()))
+			continue
+		}
+		key := match[1] + "_" + match[2]
+		if pairs[key] == nil {
+			pairs[key] = make(map[string]bool)
+		}
+		pairs[key][match[3]] = true
+
+		if match[3] != "up" {
+			continue
+		}
+		data, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return err
+		}
+		sql := string(data)
+		if !strings.Contains(sql, DestructiveMarker) {
+			if stmt := findDestructive(lineComment.ReplaceAllString(sql, "")); stmt != "" {
+				errs = append(errs, fmt.Errorf("%s: contains %q; add %q if this is intended", e.Name(), stmt, DestructiveMarker))
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(pairs))
+	for key := range pairs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, direction := range []string{"up", "down"} {
+			if !pairs[key][direction] {
+				errs = append(errs, fmt.Errorf("%s: missing %s.%s.sql", key, key, direction))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// findDestructive returns the first DROP in sql that drops a table or a
+// column, or "" if there is none.
+func findDestructive(sql string) string {
+	if stmt := dropTable.FindString(sql); stmt != "" {
+		return stmt
+	}
+	for _, stmt := range strings.Split(sql, ";") {
+		if !alterTable.MatchString(stmt) {
+			continue
+		}
+		for _, m := range alterDrop.FindAllStringSubmatch(stmt, -1) {
+			if !keptDrops[strings.ToUpper(m[1])] {
+				return m[0]
+			}
+		}
+	}
+	return ""
+}
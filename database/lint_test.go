@@ -0,0 +1,92 @@
+package database
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// migration returns a valid pair of migrations whose up file holds up.
+func migration(up string) fstest.MapFS {
+	return fstest.MapFS{
+		"000001_change.up.sql":   {Data: []byte(up)},
+		"000001_change.down.sql": {Data: []byte("SELECT 1;")},
+	}
+}
+
+func TestLintDestructive(t *testing.T) {
+	for _, tc := range []struct {
+		name, up string
+		want     string
+	}{
+		{"drop table", "DROP TABLE users;", "DROP TABLE"},
+		{"drop table if exists", "drop table if exists users;", "drop table"},
+		{"drop column", "ALTER TABLE users DROP COLUMN email;", "DROP COLUMN"},
+		{"drop without COLUMN", "ALTER TABLE users DROP email;", "DROP email"},
+		{"drop if exists", "ALTER TABLE IF EXISTS users DROP COLUMN IF EXISTS email;", "DROP COLUMN"},
+		{"drop if exists without COLUMN", "alter table only users drop if exists email;", "drop if"},
+		{"among other actions", "ALTER TABLE users\n    ADD COLUMN nickname TEXT,\n    DROP   email;", "DROP   email"},
+		{"after another statement", "CREATE INDEX i ON users (name);\nALTER TABLE users DROP email;", "DROP email"},
+
+		{"drop index", "DROP INDEX IF EXISTS users_name_idx;", ""},
+		{"drop constraint", "ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key;", ""},
+		{"drop default", "ALTER TABLE users ALTER COLUMN email DROP DEFAULT;", ""},
+		{"drop not null", "ALTER TABLE users ALTER COLUMN email DROP NOT NULL;", ""},
+		{"drop identity", "ALTER TABLE users ALTER COLUMN id DROP IDENTITY IF EXISTS;", ""},
+		{"drop trigger", "DROP TRIGGER IF EXISTS users_audit ON users;", ""},
+		{"commented out", "-- ALTER TABLE users DROP email;\nSELECT 1;", ""},
+		{"marked", DestructiveMarker + "\nALTER TABLE users DROP email;", ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Lint(migration(tc.up))
+			if tc.want == "" {
+				if err != nil {
+					t.Fatalf("Lint = %v, want no error", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), `"`+tc.want+`"`) {
+				t.Fatalf("Lint = %v, want it to report %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestLintIgnoresDownMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"000001_users.up.sql":   {Data: []byte("CREATE TABLE users (id BIGSERIAL);")},
+		"000001_users.down.sql": {Data: []byte("DROP TABLE users;")},
+	}
+	if err := Lint(fsys); err != nil {
+		t.Fatalf("Lint = %v, want no error", err)
+	}
+}
+
+func TestLintNamesAndPairs(t *testing.T) {
+	fsys := fstest.MapFS{
+		"000001_users.up.sql":   {Data: []byte("SELECT 1;")},
+		"000002_audit.down.sql": {Data: []byte("SELECT 1;")},
+		"3_bad-name.up.sql":     {Data: []byte("SELECT 1;")},
+		"README.md":             {Data: []byte("not a migration")},
+	}
+	err := Lint(fsys)
+	if err == nil {
+		t.Fatal("Lint = nil, want errors")
+	}
+	for _, want := range []string{"missing 000001_users.down.sql", "missing 000002_audit.up.sql", "3_bad-name.up.sql: name must look like"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Lint error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestLintRepositoryMigrations(t *testing.T) {
+	fsys, err := fs.Sub(Migrations, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Lint(fsys); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1 +1,2 @@
-drop table if extists users cascade;
\ No newline at end of file
+ALTER TABLE users DROP COLUMN IF EXISTS created_at;
+ALTER TABLE users DROP COLUMN IF EXISTS email;
@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS users_audit ON users;
+DROP FUNCTION IF EXISTS audit_user_change();
@@ -0,0 +1,31 @@
+CREATE OR REPLACE FUNCTION audit_user_change() RETURNS trigger AS $$
+DECLARE
+    act TEXT;
+    entry audit_logs%ROWTYPE;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        act := 'create';
+    ELSIF OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN
+        act := 'delete';
+    ELSIF OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL THEN
+        act := 'restore';
+    ELSIF OLD.name IS DISTINCT FROM NEW.name OR OLD.email IS DISTINCT FROM NEW.email THEN
+        act := 'update';
+    ELSE
+        RETURN NULL;
+    END IF;
+
+    INSERT INTO audit_logs (user_id, action) VALUES (NEW.id, act) RETURNING * INTO entry;
+    PERFORM pg_notify('user_events', json_build_object(
+        'id', entry.id,
+        'user_id', entry.user_id,
+        'action', entry.action,
+        'created_at', to_char(entry.created_at, 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"')
+    )::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS users_audit ON users;
+CREATE TRIGGER users_audit AFTER INSERT OR UPDATE ON users
+    FOR EACH ROW EXECUTE FUNCTION audit_user_change();
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS users_email_trgm_idx;
+DROP INDEX IF EXISTS users_name_trgm_idx;
+DROP INDEX IF EXISTS users_search_idx;
+ALTER TABLE users DROP COLUMN IF EXISTS search;
+-- pg_trgm stays installed; other objects may have come to depend on it.
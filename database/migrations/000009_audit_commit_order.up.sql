@@ -0,0 +1,59 @@
+-- Audit log IDs are taken at insert, but transactions commit in another
+-- order, so resuming the change feed after an ID can skip a lower one that
+-- committed later. seq is taken while the transaction commits, under a lock
+-- held until its rows are visible, so it grows in commit order.
+CREATE SEQUENCE IF NOT EXISTS audit_logs_seq;
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS seq BIGINT;
+ALTER SEQUENCE audit_logs_seq OWNED BY audit_logs.seq;
+
+UPDATE audit_logs a SET seq = o.n
+FROM (SELECT id, row_number() OVER (ORDER BY id) AS n FROM audit_logs) o
+WHERE a.id = o.id AND a.seq IS NULL;
+SELECT setval('audit_logs_seq', (SELECT COALESCE(MAX(seq), 0) + 1 FROM audit_logs), false);
+
+CREATE UNIQUE INDEX IF NOT EXISTS audit_logs_seq_idx ON audit_logs (seq);
+
+CREATE OR REPLACE FUNCTION audit_user_change() RETURNS trigger AS $$
+DECLARE
+    act TEXT;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        act := 'create';
+    ELSIF OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN
+        act := 'delete';
+    ELSIF OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL THEN
+        act := 'restore';
+    ELSIF OLD.name IS DISTINCT FROM NEW.name OR OLD.email IS DISTINCT FROM NEW.email THEN
+        act := 'update';
+    ELSE
+        RETURN NULL;
+    END IF;
+
+    INSERT INTO audit_logs (user_id, action) VALUES (NEW.id, act);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Runs at commit. The advisory lock is released only after the commit is
+-- visible, so no entry can get a lower seq than one already readable.
+CREATE OR REPLACE FUNCTION sequence_audit_log() RETURNS trigger AS $$
+DECLARE
+    entry audit_logs%ROWTYPE;
+BEGIN
+    PERFORM pg_advisory_xact_lock(hashtext('audit_logs_seq'));
+    UPDATE audit_logs SET seq = nextval('audit_logs_seq') WHERE id = NEW.id RETURNING * INTO entry;
+    PERFORM pg_notify('user_events', json_build_object(
+        'id', entry.id,
+        'seq', entry.seq,
+        'user_id', entry.user_id,
+        'action', entry.action,
+        'created_at', to_char(entry.created_at::timestamptz AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"')
+    )::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_logs_sequence ON audit_logs;
+CREATE CONSTRAINT TRIGGER audit_logs_sequence AFTER INSERT ON audit_logs
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION sequence_audit_log();
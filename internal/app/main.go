@@ -2,92 +2,275 @@ package app
 
 import (
 	"context"
+	"expvar"
+	"flag"
+	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
-	"os"
 	"os/signal"
+	"practice4/practice-4/internal/config"
+	"practice4/practice-4/internal/events"
+	"practice4/practice-4/internal/gql"
+	"practice4/practice-4/internal/grpcapi"
 	"practice4/practice-4/internal/handler"
 	"practice4/practice-4/internal/repository"
 	"practice4/practice-4/internal/repository/_postgres"
+	"practice4/practice-4/internal/repository/cache"
 	"practice4/practice-4/internal/router"
 	"practice4/practice-4/internal/usecase"
-	"practice4/practice-4/pkg/modules"
-	"strconv"
+	"practice4/practice-4/internal/webhooks"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/joho/godotenv"
+	"google.golang.org/grpc"
 )
 
-func Run() {
+const usage = `usage: api [command] [flags]
+
+commands:
+  serve                      run the HTTP server (default)
+  migrate [flags] <action>   manage database migrations, run "api migrate" for actions
+  schema check [flags]       report drift between the database and the migrations
+  seed [flags]               load fixture users or generate fake ones
+  openapi check              verify the OpenAPI spec matches the registered routes
+`
+
+// countCacheSize bounds the totals kept for the "cached" count strategy, one
+// per distinct filter.
+const countCacheSize = 1000
+
+// Run dispatches args to a subcommand; without one it serves HTTP.
+func Run(args []string) error {
 	_ = godotenv.Load()
 
-	dbConfig := initPostgreConfig()
+	cmd := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	switch cmd {
+	case "serve":
+		return serve(ctx, args)
+	case "migrate":
+		return migrateCmd(ctx, args)
+	case "schema":
+		return schemaCmd(ctx, args)
+	case "seed":
+		return seedCmd(ctx, args)
+	case "openapi":
+		return openapiCmd(args)
+	case "help":
+		fmt.Print(usage)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q\n%s", cmd, usage)
+	}
+}
+
+func serve(ctx context.Context, args []string) error {
+	cfg, err := config.Load(flag.NewFlagSet("serve", flag.ContinueOnError), args, config.All)
+	if err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	log.Printf("effective configuration: %+v", cfg.Redacted())
+
+	validator, err := newValidator(cfg.Server.OpenAPIValidation)
+	if err != nil {
+		return err
+	}
 
-	db := _postgres.NewPGXDialect(context.Background(), dbConfig)
+	db, err := _postgres.NewPGXDialect(ctx, &cfg.Postgres)
+	if err != nil {
+		if ctx.Err() != nil {
+			log.Println("Startup interrupted")
+			return nil
+		}
+		return err
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			log.Printf("db close error: %v", err)
+		}
+	}()
 
-	repos := repository.NewRepositories(db)
-	uc := usecase.NewUserUsecase(repos.Users)
-	h := handler.NewUserHandler(uc)
+	if cfg.Postgres.AutoMigrate {
+		if err := _postgres.AutoMigrate(ctx, &cfg.Postgres); err != nil {
+			return err
+		}
+	}
 
-	apiKey := mustEnv("API_KEY")
-	r := router.NewRouter(h, apiKey)
+	repos := repository.NewRepositories(db, &cfg.Postgres)
+	stopWorkers, err := startWorkers(ctx, repos, cfg)
+	if err != nil {
+		return err
+	}
+	defer stopWorkers()
+
+	broker := events.NewBroker(repos.Changes)
+	go broker.Run(ctx)
+	if cfg.Cache.Backend == "memory" {
+		cached := cache.NewUserRepository(repos.Users, cache.NewLRU(cfg.Cache.Size), cfg.Cache.TTL)
+		expvar.Publish("user_cache", expvar.Func(func() any { return cached.Stats() }))
+		go invalidateOnChange(ctx, broker, cached)
+		repos.Users = cached
+	}
+
+	uc := usecase.NewUserUsecase(repos.Users, repos.Tx, cfg.Count, cache.NewLRU(countCacheSize))
+	gqlHandler, err := gql.NewHandler(uc)
+	if err != nil {
+		return err
+	}
+	hs := router.Handlers{
+		Users:      handler.NewUserHandler(uc),
+		Webhooks:   handler.NewWebhookHandler(usecase.NewWebhookUsecase(repos.Webhooks, repos.Tx)),
+		UserEvents: handler.NewUserEventsHandler(uc, broker, cfg.Server.SSEHeartbeat),
+		GraphQL:    gqlHandler,
+	}
+
+	r := router.NewRouter(hs, cfg.APIKey, validator)
 
 	srv := &http.Server{
-		Addr:    ":8080",
-		Handler: r,
+		Addr:         cfg.Server.Addr,
+		Handler:      readYourWrites(r),
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
 	}
+	// Shutdown waits for active requests, so end the event streams first.
+	srv.RegisterOnShutdown(broker.Close)
 
+	errCh := make(chan error, 2)
 	go func() {
-		log.Println("Starting the Server...")
+		log.Printf("Starting the Server on %s...", cfg.Server.Addr)
 		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
-			log.Fatalf("server error: %v", err)
+			errCh <- err
 		}
 	}()
 
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	var grpcSrv *grpc.Server
+	if cfg.Server.GRPCAddr != "off" {
+		lis, err := net.Listen("tcp", cfg.Server.GRPCAddr)
+		if err != nil {
+			return fmt.Errorf("grpc listen: %w", err)
+		}
+		grpcSrv = grpcapi.NewGRPCServer(uc, cfg.APIKey)
+		go func() {
+			log.Printf("Starting the gRPC server on %s...", cfg.Server.GRPCAddr)
+			if err := grpcSrv.Serve(lis); err != nil {
+				errCh <- fmt.Errorf("grpc: %w", err)
+			}
+		}()
+	}
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("server error: %w", err)
+	case <-ctx.Done():
+	}
 
 	log.Println("Shutting down gracefully...")
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 
-	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatalf("shutdown error: %v", err)
+	var grpcStopped chan struct{}
+	if grpcSrv != nil {
+		grpcStopped = make(chan struct{})
+		go func() {
+			grpcSrv.GracefulStop()
+			close(grpcStopped)
+		}()
 	}
 
-	if err := db.Close(); err != nil {
-		log.Printf("db close error: %v", err)
+	httpErr := srv.Shutdown(shutdownCtx)
+	if grpcSrv != nil {
+		select {
+		case <-grpcStopped:
+		case <-shutdownCtx.Done():
+			grpcSrv.Stop()
+		}
+	}
+	if httpErr != nil {
+		return fmt.Errorf("shutdown error: %w", httpErr)
 	}
 
 	log.Println("Server stopped")
+	return nil
 }
 
-func mustEnv(key string) string {
-	val := os.Getenv(key)
-	if val == "" {
-		log.Fatalf("missing required environment variable: %s", key)
+// startWorkers runs the outbox relay, which fans events out to webhook
+// subscriptions and the configured publisher, and the webhook dispatcher in
+// the background. The returned function stops both, waits for them and
+// releases the publisher.
+func startWorkers(ctx context.Context, repos *repository.Repositories, cfg *config.Config) (func(), error) {
+	pubs := events.Multi{webhooks.NewFanout(repos.Webhooks)}
+	if cfg.Events.Publisher != "off" {
+		pub, err := events.NewPublisher(&cfg.Events)
+		if err != nil {
+			return nil, err
+		}
+		pubs = append(pubs, pub)
 	}
-	return val
+
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		log.Printf("Starting the outbox relay (%s publisher)...", cfg.Events.Publisher)
+		events.NewRelay(repos.Outbox, pubs, cfg.Events).Run(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		webhooks.NewDispatcher(repos.Webhooks, cfg.Webhooks).Run(ctx)
+	}()
+
+	return func() {
+		cancel()
+		wg.Wait()
+		for _, pub := range pubs {
+			if c, ok := pub.(io.Closer); ok {
+				if err := c.Close(); err != nil {
+					log.Printf("event publisher close error: %v", err)
+				}
+			}
+		}
+	}, nil
 }
 
-func getEnv(key, fallback string) string {
-	if val := os.Getenv(key); val != "" {
-		return val
+// invalidateOnChange keeps an in-process cache coherent with writes made by
+// other instances. When the broker drops the subscription, changes may have
+// been missed, so the whole cache is invalidated before resubscribing.
+func invalidateOnChange(ctx context.Context, broker *events.Broker, cached *cache.UserRepository) {
+	for {
+		changes, unsubscribe := broker.Subscribe()
+		for range changes {
+			cached.Invalidate(ctx)
+		}
+		unsubscribe()
+		cached.Invalidate(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
 	}
-	return fallback
 }
 
-func initPostgreConfig() *modules.PostgreConfig {
-	port, _ := strconv.Atoi(getEnv("DB_PORT", "5432"))
-	return &modules.PostgreConfig{
-		Host:        mustEnv("DB_HOST"),
-		Port:        port,
-		Username:    mustEnv("DB_USER"),
-		Password:    getEnv("DB_PASSWORD", ""),
-		DBName:      mustEnv("DB_NAME"),
-		SSLMode:     getEnv("DB_SSLMODE", "disable"),
-		ExecTimeout: 5 * time.Second,
-	}
+// readYourWrites sends a request's reads to the primary once it has made a
+// change, so replica lag never hides that change from the same request.
+// The guarantee ends with the request: a client's next request may still
+// read from a replica that has not caught up with its previous write.
+func readYourWrites(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(_postgres.TrackWrites(r.Context())))
+	})
 }
@@ -0,0 +1,202 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"practice4/practice-4/database"
+	"practice4/practice-4/internal/config"
+	"practice4/practice-4/internal/repository/_postgres"
+	"practice4/practice-4/pkg/modules"
+	"regexp"
+	"strconv"
+
+	"github.com/golang-migrate/migrate/v4"
+)
+
+const migrateUsage = `usage: api migrate [flags] <action>
+
+actions:
+  up [N]        apply all or N pending migrations
+  down N        roll back N migrations
+  goto V        migrate up or down to version V
+  version       print the current version and dirty state
+  force V       set the version to V without running migrations
+  create NAME   write empty up/down files for a new migration
+  lint [-dir D] check migrations for missing pairs and unmarked destructive statements
+`
+
+var migrationName = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+func migrateCmd(ctx context.Context, args []string) error {
+	if len(args) > 0 && args[0] == "create" {
+		return createMigration(args[1:])
+	}
+	if len(args) > 0 && args[0] == "lint" {
+		return lintMigrations(args[1:])
+	}
+
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	cfg, err := config.Load(fs, args, config.Postgres)
+	if err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	rest := fs.Args()
+	if len(rest) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	action, params := rest[0], rest[1:]
+	if action == "version" {
+		return printVersion(&cfg.Postgres)
+	}
+
+	err = _postgres.WithMigrationLock(ctx, &cfg.Postgres, func() error {
+		m, err := _postgres.NewMigrator(&cfg.Postgres)
+		if err != nil {
+			return err
+		}
+		defer m.Close()
+		defer _postgres.StopOnCancel(ctx, m)()
+
+		if action != "force" {
+			if err := _postgres.CheckDirty(m); err != nil {
+				return err
+			}
+		}
+
+		switch action {
+		case "up":
+			if len(params) == 0 {
+				return m.Up()
+			}
+			n, err := positiveArg(params)
+			if err != nil {
+				return err
+			}
+			return m.Steps(n)
+		case "down":
+			n, err := positiveArg(params)
+			if err != nil {
+				return err
+			}
+			return m.Steps(-n)
+		case "goto":
+			v, err := positiveArg(params)
+			if err != nil {
+				return err
+			}
+			return m.Migrate(uint(v))
+		case "force":
+			v, err := versionArg(params)
+			if err != nil {
+				return err
+			}
+			return m.Force(v)
+		default:
+			return fmt.Errorf("unknown migrate action %q\n%s", action, migrateUsage)
+		}
+	})
+	if errors.Is(err, migrate.ErrNoChange) {
+		log.Println("migrate: no change")
+		return nil
+	}
+	return err
+}
+
+func printVersion(cfg *modules.PostgreConfig) error {
+	m, err := _postgres.NewMigrator(cfg)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	version, dirty, err := m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		fmt.Println("no migrations applied")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("version %d (dirty: %t)\n", version, dirty)
+	return nil
+}
+
+func lintMigrations(args []string) error {
+	fs := flag.NewFlagSet("migrate lint", flag.ContinueOnError)
+	dir := fs.String("dir", "", "lint migrations on disk instead of the embedded ones")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	fsys := _postgres.MigrationsFS()
+	if *dir != "" {
+		fsys = os.DirFS(*dir)
+	}
+	if err := database.Lint(fsys); err != nil {
+		return fmt.Errorf("migration lint failed:\n%w", err)
+	}
+	fmt.Println("migrations ok")
+	return nil
+}
+
+func positiveArg(params []string) (int, error) {
+	if len(params) != 1 {
+		return 0, errors.New(migrateUsage)
+	}
+	n, err := strconv.Atoi(params[0])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("expected a positive number, got %q", params[0])
+	}
+	return n, nil
+}
+
+func versionArg(params []string) (int, error) {
+	if len(params) != 1 {
+		return 0, errors.New(migrateUsage)
+	}
+	v, err := strconv.Atoi(params[0])
+	if err != nil || v < -1 {
+		return 0, fmt.Errorf("expected a version number, got %q", params[0])
+	}
+	return v, nil
+}
+
+func createMigration(args []string) error {
+	fs := flag.NewFlagSet("migrate create", flag.ContinueOnError)
+	dir := fs.String("dir", _postgres.MigrationsDir, "directory to write migration files to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || !migrationName.MatchString(fs.Arg(0)) {
+		return errors.New("usage: api migrate create [-dir DIR] NAME (lowercase letters, digits and underscores)")
+	}
+
+	entries, err := os.ReadDir(*dir)
+	if err != nil {
+		return err
+	}
+	next := 1
+	for _, e := range entries {
+		var v int
+		if _, err := fmt.Sscanf(e.Name(), "%06d_", &v); err == nil && v >= next {
+			next = v + 1
+		}
+	}
+
+	for _, direction := range []string{"up", "down"} {
+		path := filepath.Join(*dir, fmt.Sprintf("%06d_%s.%s.sql", next, fs.Arg(0), direction))
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			return err
+		}
+		f.Close()
+		fmt.Println(path)
+	}
+	return nil
+}
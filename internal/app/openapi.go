@@ -0,0 +1,49 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"practice4/practice-4/internal/openapi"
+	"practice4/practice-4/internal/router"
+)
+
+const openapiUsage = `usage: api openapi check
+
+Verifies that the OpenAPI spec compiles and that it describes exactly the
+routes the server registers. Exits non-zero on drift.
+`
+
+func openapiCmd(args []string) error {
+	if len(args) == 0 || args[0] != "check" {
+		return errors.New(openapiUsage)
+	}
+	spec, err := openapi.Load()
+	if err != nil {
+		return err
+	}
+	if err := spec.CheckRoutes(router.Patterns()); err != nil {
+		return fmt.Errorf("OpenAPI spec drift:\n%w", err)
+	}
+	fmt.Println("OpenAPI spec matches the registered routes")
+	return nil
+}
+
+// newValidator builds the OpenAPI validator for mode. With response
+// validation on, route drift is fatal so that it surfaces in tests.
+func newValidator(mode string) (*openapi.Validator, error) {
+	if mode == "off" {
+		return nil, nil
+	}
+	spec, err := openapi.Load()
+	if err != nil {
+		return nil, err
+	}
+	if err := spec.CheckRoutes(router.Patterns()); err != nil {
+		if mode == "all" {
+			return nil, fmt.Errorf("OpenAPI spec drift:\n%w", err)
+		}
+		log.Printf("OpenAPI spec drift: %v", err)
+	}
+	return openapi.NewValidator(spec, mode == "all"), nil
+}
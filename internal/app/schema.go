@@ -0,0 +1,46 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"practice4/practice-4/internal/config"
+	"practice4/practice-4/internal/repository/_postgres"
+)
+
+const schemaUsage = `usage: api schema check [flags]
+
+Compares the live tables, indexes and constraints with the schema the applied
+migrations describe and prints the differences as JSON. Exits non-zero on drift.
+`
+
+var errSchemaDrift = errors.New("schema drift detected")
+
+func schemaCmd(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "check" {
+		return errors.New(schemaUsage)
+	}
+
+	cfg, err := config.Load(flag.NewFlagSet("schema check", flag.ContinueOnError), args[1:], config.Postgres)
+	if err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	report, err := _postgres.CheckSchema(ctx, &cfg.Postgres)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	if report.Drift {
+		return errSchemaDrift
+	}
+	return nil
+}
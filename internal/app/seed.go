@@ -0,0 +1,49 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"practice4/practice-4/internal/config"
+	"practice4/practice-4/internal/repository"
+	"practice4/practice-4/internal/repository/_postgres"
+	"practice4/practice-4/internal/seed"
+	"practice4/practice-4/pkg/modules"
+)
+
+func seedCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	file := fs.String("file", "", "YAML or JSON fixture file with a top-level users list")
+	count := fs.Int("count", 0, "number of fake users to generate")
+	seedValue := fs.Int64("seed", 1, "random seed for generated users")
+	truncate := fs.Bool("truncate", false, "delete all users, audit logs, events and webhook deliveries first")
+	cfg, err := config.Load(fs, args, config.Postgres)
+	if err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	if (*file == "") == (*count <= 0) {
+		return errors.New("usage: api seed (-file PATH | -count N [-seed S]) [-truncate] [flags]")
+	}
+
+	var users []modules.User
+	if *file != "" {
+		if users, err = seed.LoadFixtures(*file); err != nil {
+			return err
+		}
+	} else {
+		users = seed.Fake(*count, *seedValue)
+	}
+
+	db, err := _postgres.NewPGXDialect(ctx, &cfg.Postgres)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	repos := repository.NewRepositories(db, &cfg.Postgres)
+	n, err := seed.Load(ctx, repos.Users, users, *truncate)
+	log.Printf("seed: inserted %d users", n)
+	return err
+}
@@ -0,0 +1,532 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"practice4/practice-4/pkg/modules"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "******"
+
+// Section selects which parts of the configuration a command needs, so that
+// e.g. migrations can run without an API key.
+type Section uint8
+
+const (
+	Server Section = 1 << iota
+	Postgres
+	Auth
+
+	All = Server | Postgres | Auth
+)
+
+type Config struct {
+	Server   modules.ServerConfig   `yaml:"server" toml:"server"`
+	Postgres modules.PostgreConfig  `yaml:"postgres" toml:"postgres"`
+	Events   modules.EventsConfig   `yaml:"events" toml:"events"`
+	Webhooks modules.WebhooksConfig `yaml:"webhooks" toml:"webhooks"`
+	Cache    modules.CacheConfig    `yaml:"cache" toml:"cache"`
+	Count    modules.CountConfig    `yaml:"count" toml:"count"`
+	APIKey   string                 `yaml:"api_key" toml:"api_key"`
+}
+
+func Default() *Config {
+	return &Config{
+		Server: modules.ServerConfig{
+			Addr:              ":8080",
+			GRPCAddr:          ":9090",
+			ReadTimeout:       10 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       60 * time.Second,
+			ShutdownTimeout:   5 * time.Second,
+			OpenAPIValidation: "requests",
+			SSEHeartbeat:      15 * time.Second,
+		},
+		Postgres: modules.PostgreConfig{
+			Port:            5432,
+			SSLMode:         "disable",
+			ExecTimeout:     5 * time.Second,
+			ApplicationName: "practice4-api",
+			MaxOpenConns:    25,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+			Retry: modules.RetryPolicy{
+				InitialInterval: 500 * time.Millisecond,
+				MaxInterval:     10 * time.Second,
+				MaxElapsed:      time.Minute,
+			},
+			ReplicaHealthInterval: 5 * time.Second,
+			TxIsolation:           "read-committed",
+			TxMaxRetries:          3,
+		},
+		Events: modules.EventsConfig{
+			Publisher:      "off",
+			WebhookTimeout: 10 * time.Second,
+			PollInterval:   time.Second,
+			BatchSize:      100,
+			MaxAttempts:    10,
+		},
+		Webhooks: modules.WebhooksConfig{
+			MaxAttempts:  8,
+			RetryInitial: 10 * time.Second,
+			RetryMax:     time.Hour,
+			Timeout:      10 * time.Second,
+			PollInterval: time.Second,
+			BatchSize:    20,
+		},
+		Cache: modules.CacheConfig{
+			Backend: "memory",
+			Size:    10000,
+			TTL:     30 * time.Second,
+		},
+		Count: modules.CountConfig{
+			Strategy: "exact",
+			TTL:      time.Minute,
+		},
+	}
+}
+
+// Load builds the configuration from defaults, an optional YAML/TOML file,
+// environment variables and command-line flags, in increasing precedence,
+// and validates the requested sections. The configuration flags are added to
+// fs, which may already carry command-specific flags; positional arguments
+// remain available through fs.Args.
+func Load(fs *flag.FlagSet, args []string, sections Section) (*Config, error) {
+	cfg := Default()
+
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML or TOML config file")
+
+	var fromFlags []func() error
+	for _, f := range fields {
+		if f.flag == "" {
+			continue
+		}
+		f := f
+		fs.Func(f.flag, f.usage, func(v string) error {
+			fromFlags = append(fromFlags, func() error { return f.apply(cfg, "-"+f.flag, v) })
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *configFile != "" {
+		if err := loadFile(cfg, *configFile); err != nil {
+			return nil, err
+		}
+	}
+
+	var errs []error
+	for _, pair := range exclusiveEnv {
+		if os.Getenv(pair[0]) != "" && os.Getenv(pair[1]) != "" {
+			errs = append(errs, fmt.Errorf("%s and %s are both set, use one", pair[0], pair[1]))
+		}
+	}
+	for _, f := range fields {
+		if v, ok := os.LookupEnv(f.env); ok && v != "" {
+			if err := f.apply(cfg, f.env, v); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	for _, apply := range fromFlags {
+		if err := apply(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(append(errs, cfg.Validate(sections))...); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err = dec.Decode(cfg); err == io.EOF {
+			err = nil
+		}
+	case ".toml":
+		var md toml.MetaData
+		if md, err = toml.Decode(string(data), cfg); err == nil {
+			if undecoded := md.Undecoded(); len(undecoded) > 0 {
+				keys := make([]string, len(undecoded))
+				for i, k := range undecoded {
+					keys[i] = k.String()
+				}
+				err = fmt.Errorf("unknown keys %s", strings.Join(keys, ", "))
+			}
+		}
+	default:
+		return fmt.Errorf("config file %s: unsupported format, use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *Config) Validate(sections Section) error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	if sections&Server != 0 {
+		c.validateServer(check)
+		c.validateEvents(check)
+		c.validateWebhooks(check)
+		c.validateCache(check)
+	}
+	if sections&Postgres != 0 {
+		c.validatePostgres(check)
+		c.validateCount(check)
+	}
+	if sections&Auth != 0 {
+		check(c.APIKey != "", "api_key is required (API_KEY or API_KEY_FILE)")
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Config) validateServer(check func(bool, string, ...any)) {
+	_, _, err := net.SplitHostPort(c.Server.Addr)
+	check(err == nil, "server.addr %q: %v", c.Server.Addr, err)
+	if c.Server.GRPCAddr != "off" {
+		_, _, err := net.SplitHostPort(c.Server.GRPCAddr)
+		check(err == nil, "server.grpc_addr %q: %v", c.Server.GRPCAddr, err)
+	}
+	check(c.Server.ReadTimeout >= 0, "server.read_timeout must not be negative")
+	check(c.Server.WriteTimeout >= 0, "server.write_timeout must not be negative")
+	check(c.Server.IdleTimeout >= 0, "server.idle_timeout must not be negative")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout must be positive")
+	check(c.Server.SSEHeartbeat > 0, "server.sse_heartbeat must be positive")
+	switch c.Server.OpenAPIValidation {
+	case "off", "requests", "all":
+	default:
+		check(false, "server.openapi_validation %q must be off, requests or all", c.Server.OpenAPIValidation)
+	}
+}
+
+func (c *Config) validateEvents(check func(bool, string, ...any)) {
+	switch c.Events.Publisher {
+	case "off", "stdout":
+	case "file":
+		check(c.Events.File != "", "events.file is required for the file publisher (EVENTS_FILE)")
+	case "webhook":
+		u, err := url.Parse(c.Events.WebhookURL)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			"events.webhook_url must be an http(s) URL for the webhook publisher (EVENTS_WEBHOOK_URL)")
+		check(c.Events.WebhookTimeout > 0, "events.webhook_timeout must be positive")
+	default:
+		check(false, "events.publisher %q must be off, stdout, file or webhook", c.Events.Publisher)
+	}
+	check(c.Events.PollInterval > 0, "events.poll_interval must be positive")
+	check(c.Events.BatchSize > 0, "events.batch_size must be positive")
+	check(c.Events.MaxAttempts > 0, "events.max_attempts must be positive")
+}
+
+func (c *Config) validateWebhooks(check func(bool, string, ...any)) {
+	check(c.Webhooks.MaxAttempts > 0, "webhooks.max_attempts must be positive")
+	check(c.Webhooks.RetryInitial > 0, "webhooks.retry_initial must be positive")
+	check(c.Webhooks.RetryMax >= c.Webhooks.RetryInitial, "webhooks.retry_max must not be below retry_initial")
+	check(c.Webhooks.Timeout > 0, "webhooks.timeout must be positive")
+	check(c.Webhooks.PollInterval > 0, "webhooks.poll_interval must be positive")
+	check(c.Webhooks.BatchSize > 0, "webhooks.batch_size must be positive")
+}
+
+func (c *Config) validateCache(check func(bool, string, ...any)) {
+	switch c.Cache.Backend {
+	case "off":
+	case "memory":
+		check(c.Cache.Size > 0, "cache.size must be positive")
+		check(c.Cache.TTL > 0, "cache.ttl must be positive")
+	default:
+		check(false, "cache.backend %q must be off or memory", c.Cache.Backend)
+	}
+}
+
+func (c *Config) validateCount(check func(bool, string, ...any)) {
+	switch c.Count.Strategy {
+	case "exact", "estimated":
+	case "cached":
+		check(c.Count.TTL > 0, "count.ttl must be positive")
+	default:
+		check(false, "count.strategy %q must be exact, estimated or cached", c.Count.Strategy)
+	}
+}
+
+func (c *Config) validatePostgres(check func(bool, string, ...any)) {
+	if c.Postgres.URL != "" {
+		u, err := url.Parse(c.Postgres.URL)
+		check(err == nil, "postgres.url is not a valid URL")
+		if err == nil {
+			check(u.Scheme == "postgres" || u.Scheme == "postgresql", "postgres.url must use the postgres:// scheme")
+		}
+	} else {
+		check(c.Postgres.Host != "", "postgres.host is required (DB_HOST or DATABASE_URL)")
+		check(c.Postgres.Port > 0 && c.Postgres.Port <= 65535, "postgres.port %d is out of range", c.Postgres.Port)
+		check(c.Postgres.Username != "", "postgres.user is required (DB_USER or DATABASE_URL)")
+		check(c.Postgres.DBName != "", "postgres.name is required (DB_NAME or DATABASE_URL)")
+		switch c.Postgres.SSLMode {
+		case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+		default:
+			check(false, "postgres.sslmode %q is not a valid sslmode", c.Postgres.SSLMode)
+		}
+	}
+	check(c.Postgres.ExecTimeout > 0, "postgres.exec_timeout must be positive")
+	check(c.Postgres.StatementTimeout >= 0, "postgres.statement_timeout must not be negative")
+	check(c.Postgres.MaxOpenConns >= 0, "postgres.max_open_conns must not be negative")
+	check(c.Postgres.MaxIdleConns >= 0, "postgres.max_idle_conns must not be negative")
+	check(c.Postgres.MaxOpenConns == 0 || c.Postgres.MaxIdleConns <= c.Postgres.MaxOpenConns,
+		"postgres.max_idle_conns (%d) must not exceed postgres.max_open_conns (%d)", c.Postgres.MaxIdleConns, c.Postgres.MaxOpenConns)
+	check(c.Postgres.ConnMaxLifetime >= 0, "postgres.conn_max_lifetime must not be negative")
+	check(c.Postgres.ConnMaxIdleTime >= 0, "postgres.conn_max_idle_time must not be negative")
+	check(c.Postgres.Retry.InitialInterval > 0, "postgres.retry.initial_interval must be positive")
+	check(c.Postgres.Retry.MaxInterval >= c.Postgres.Retry.InitialInterval, "postgres.retry.max_interval must not be below initial_interval")
+	check(c.Postgres.Retry.MaxElapsed >= 0, "postgres.retry.max_elapsed must not be negative")
+	for op, d := range c.Postgres.OpTimeouts {
+		check(d > 0, "postgres.op_timeouts.%s must be positive", op)
+	}
+	for i, replica := range c.Postgres.ReplicaURLs {
+		u, err := url.Parse(replica)
+		check(err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql"),
+			"postgres.replica_urls[%d] must be a postgres:// URL", i)
+	}
+	check(len(c.Postgres.ReplicaURLs) == 0 || c.Postgres.ReplicaHealthInterval > 0,
+		"postgres.replica_health_interval must be positive")
+	switch c.Postgres.TxIsolation {
+	case "read-committed", "repeatable-read", "serializable":
+	default:
+		check(false, "postgres.tx_isolation %q must be read-committed, repeatable-read or serializable", c.Postgres.TxIsolation)
+	}
+	check(c.Postgres.TxMaxRetries >= 0, "postgres.tx_max_retries must not be negative")
+}
+
+// Redacted returns a copy of the configuration that is safe to log.
+func (c Config) Redacted() Config {
+	if c.Postgres.Password != "" {
+		c.Postgres.Password = redacted
+	}
+	if c.Postgres.URL != "" {
+		c.Postgres.URL = redactURL(c.Postgres.URL)
+	}
+	if len(c.Postgres.ReplicaURLs) > 0 {
+		replicas := make([]string, len(c.Postgres.ReplicaURLs))
+		for i, replica := range c.Postgres.ReplicaURLs {
+			replicas[i] = redactURL(replica)
+		}
+		c.Postgres.ReplicaURLs = replicas
+	}
+	if c.Events.WebhookURL != "" {
+		c.Events.WebhookURL = redactURL(c.Events.WebhookURL)
+	}
+	if c.APIKey != "" {
+		c.APIKey = redacted
+	}
+	return c
+}
+
+// redactURL masks the password in raw's user info and in the password and
+// sslpassword query parameters that Postgres connection URLs accept.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return redacted
+	}
+	q := u.Query()
+	for _, name := range []string{"password", "sslpassword"} {
+		if q.Has(name) {
+			q.Set(name, redacted)
+		}
+	}
+	u.RawQuery = q.Encode()
+	return u.Redacted()
+}
+
+// exclusiveEnv pairs a secret's variable with its _FILE variant; setting
+// both is an error rather than letting one silently win.
+var exclusiveEnv = [][2]string{
+	{"DB_PASSWORD", "DB_PASSWORD_FILE"},
+	{"API_KEY", "API_KEY_FILE"},
+}
+
+type field struct {
+	env   string
+	flag  string
+	usage string
+	set   func(*Config, string) error
+}
+
+func (f field) apply(cfg *Config, source, v string) error {
+	if err := f.set(cfg, v); err != nil {
+		return fmt.Errorf("%s: %w", source, err)
+	}
+	return nil
+}
+
+var fields = []field{
+	{"HTTP_ADDR", "http-addr", "HTTP listen address", setString(func(c *Config) *string { return &c.Server.Addr })},
+	{"GRPC_ADDR", "grpc-addr", "gRPC listen address, or off", setString(func(c *Config) *string { return &c.Server.GRPCAddr })},
+	{"HTTP_READ_TIMEOUT", "http-read-timeout", "HTTP read timeout", setDuration(func(c *Config) *time.Duration { return &c.Server.ReadTimeout })},
+	{"HTTP_WRITE_TIMEOUT", "http-write-timeout", "HTTP write timeout", setDuration(func(c *Config) *time.Duration { return &c.Server.WriteTimeout })},
+	{"HTTP_IDLE_TIMEOUT", "http-idle-timeout", "HTTP idle timeout", setDuration(func(c *Config) *time.Duration { return &c.Server.IdleTimeout })},
+	{"HTTP_SHUTDOWN_TIMEOUT", "http-shutdown-timeout", "graceful shutdown timeout", setDuration(func(c *Config) *time.Duration { return &c.Server.ShutdownTimeout })},
+	{"SSE_HEARTBEAT", "sse-heartbeat", "interval between heartbeats on idle event streams", setDuration(func(c *Config) *time.Duration { return &c.Server.SSEHeartbeat })},
+	{"OPENAPI_VALIDATION", "openapi-validation", "validate against the OpenAPI spec: off, requests or all", setString(func(c *Config) *string { return &c.Server.OpenAPIValidation })},
+
+	{"DATABASE_URL", "database-url", "full Postgres connection URL", setString(func(c *Config) *string { return &c.Postgres.URL })},
+	{"DB_HOST", "db-host", "Postgres host", setString(func(c *Config) *string { return &c.Postgres.Host })},
+	{"DB_PORT", "db-port", "Postgres port", setInt(func(c *Config) *int { return &c.Postgres.Port })},
+	{"DB_USER", "db-user", "Postgres user", setString(func(c *Config) *string { return &c.Postgres.Username })},
+	{"DB_PASSWORD", "", "", setString(func(c *Config) *string { return &c.Postgres.Password })},
+	{"DB_PASSWORD_FILE", "db-password-file", "file containing the Postgres password", setFromFile(func(c *Config) *string { return &c.Postgres.Password })},
+	{"DB_NAME", "db-name", "Postgres database name", setString(func(c *Config) *string { return &c.Postgres.DBName })},
+	{"DB_SSLMODE", "db-sslmode", "Postgres sslmode", setString(func(c *Config) *string { return &c.Postgres.SSLMode })},
+	{"DB_EXEC_TIMEOUT", "db-exec-timeout", "per-query execution timeout", setDuration(func(c *Config) *time.Duration { return &c.Postgres.ExecTimeout })},
+	{"DB_STATEMENT_TIMEOUT", "db-statement-timeout", "server-side statement_timeout", setDuration(func(c *Config) *time.Duration { return &c.Postgres.StatementTimeout })},
+	{"DB_APPLICATION_NAME", "db-application-name", "application_name reported to Postgres", setString(func(c *Config) *string { return &c.Postgres.ApplicationName })},
+	{"DB_MAX_OPEN_CONNS", "db-max-open-conns", "maximum open connections", setInt(func(c *Config) *int { return &c.Postgres.MaxOpenConns })},
+	{"DB_MAX_IDLE_CONNS", "db-max-idle-conns", "maximum idle connections", setInt(func(c *Config) *int { return &c.Postgres.MaxIdleConns })},
+	{"DB_CONN_MAX_LIFETIME", "db-conn-max-lifetime", "maximum connection lifetime", setDuration(func(c *Config) *time.Duration { return &c.Postgres.ConnMaxLifetime })},
+	{"DB_CONN_MAX_IDLE_TIME", "db-conn-max-idle-time", "maximum connection idle time", setDuration(func(c *Config) *time.Duration { return &c.Postgres.ConnMaxIdleTime })},
+	{"DB_RETRY_INITIAL_INTERVAL", "db-retry-initial-interval", "first connect retry delay", setDuration(func(c *Config) *time.Duration { return &c.Postgres.Retry.InitialInterval })},
+	{"DB_RETRY_MAX_INTERVAL", "db-retry-max-interval", "maximum connect retry delay", setDuration(func(c *Config) *time.Duration { return &c.Postgres.Retry.MaxInterval })},
+	{"DB_RETRY_MAX_ELAPSED", "db-retry-max-elapsed", "give up connecting after this long", setDuration(func(c *Config) *time.Duration { return &c.Postgres.Retry.MaxElapsed })},
+	{"DB_AUTO_MIGRATE", "db-auto-migrate", "apply pending migrations when serving", setBool(func(c *Config) *bool { return &c.Postgres.AutoMigrate })},
+	{"DB_REPLICA_URLS", "db-replica-urls", "comma-separated read replica connection URLs", setStringSlice(func(c *Config) *[]string { return &c.Postgres.ReplicaURLs })},
+	{"DB_REPLICA_HEALTH_INTERVAL", "db-replica-health-interval", "how often read replicas are health-checked", setDuration(func(c *Config) *time.Duration { return &c.Postgres.ReplicaHealthInterval })},
+	{"DB_TX_ISOLATION", "db-tx-isolation", "isolation level of units of work: read-committed, repeatable-read or serializable", setString(func(c *Config) *string { return &c.Postgres.TxIsolation })},
+	{"DB_TX_MAX_RETRIES", "db-tx-max-retries", "retries of a unit of work after a serialization failure or deadlock", setInt(func(c *Config) *int { return &c.Postgres.TxMaxRetries })},
+	{"DB_OP_TIMEOUTS", "db-op-timeouts", "per-operation timeouts, e.g. GetAll=2s,Create=1s", setDurationMap(func(c *Config) *map[string]time.Duration { return &c.Postgres.OpTimeouts })},
+
+	{"EVENTS_PUBLISHER", "events-publisher", "outbox event publisher: off, stdout, file or webhook", setString(func(c *Config) *string { return &c.Events.Publisher })},
+	{"EVENTS_FILE", "events-file", "file the file publisher appends events to", setString(func(c *Config) *string { return &c.Events.File })},
+	{"EVENTS_WEBHOOK_URL", "events-webhook-url", "URL the webhook publisher posts events to", setString(func(c *Config) *string { return &c.Events.WebhookURL })},
+	{"EVENTS_WEBHOOK_TIMEOUT", "events-webhook-timeout", "timeout for each webhook delivery", setDuration(func(c *Config) *time.Duration { return &c.Events.WebhookTimeout })},
+	{"EVENTS_POLL_INTERVAL", "events-poll-interval", "how often the relay polls the outbox", setDuration(func(c *Config) *time.Duration { return &c.Events.PollInterval })},
+	{"EVENTS_BATCH_SIZE", "events-batch-size", "events the relay claims per batch", setInt(func(c *Config) *int { return &c.Events.BatchSize })},
+	{"EVENTS_MAX_ATTEMPTS", "events-max-attempts", "publish attempts before an event is dead", setInt(func(c *Config) *int { return &c.Events.MaxAttempts })},
+
+	{"WEBHOOKS_MAX_ATTEMPTS", "webhooks-max-attempts", "webhook delivery attempts before giving up", setInt(func(c *Config) *int { return &c.Webhooks.MaxAttempts })},
+	{"WEBHOOKS_RETRY_INITIAL", "webhooks-retry-initial", "first webhook retry delay", setDuration(func(c *Config) *time.Duration { return &c.Webhooks.RetryInitial })},
+	{"WEBHOOKS_RETRY_MAX", "webhooks-retry-max", "maximum webhook retry delay", setDuration(func(c *Config) *time.Duration { return &c.Webhooks.RetryMax })},
+	{"WEBHOOKS_TIMEOUT", "webhooks-timeout", "timeout for each webhook delivery", setDuration(func(c *Config) *time.Duration { return &c.Webhooks.Timeout })},
+	{"WEBHOOKS_POLL_INTERVAL", "webhooks-poll-interval", "how often due webhook deliveries are polled", setDuration(func(c *Config) *time.Duration { return &c.Webhooks.PollInterval })},
+	{"WEBHOOKS_BATCH_SIZE", "webhooks-batch-size", "webhook deliveries sent per transaction", setInt(func(c *Config) *int { return &c.Webhooks.BatchSize })},
+
+	{"CACHE_BACKEND", "cache-backend", "user read cache: memory or off", setString(func(c *Config) *string { return &c.Cache.Backend })},
+	{"CACHE_SIZE", "cache-size", "maximum cached user reads", setInt(func(c *Config) *int { return &c.Cache.Size })},
+	{"CACHE_TTL", "cache-ttl", "how long user reads stay cached", setDuration(func(c *Config) *time.Duration { return &c.Cache.TTL })},
+
+	{"COUNT_STRATEGY", "count-strategy", "how user listings compute their total: exact, estimated or cached", setString(func(c *Config) *string { return &c.Count.Strategy })},
+	{"COUNT_TTL", "count-ttl", "how long the cached strategy reuses a count", setDuration(func(c *Config) *time.Duration { return &c.Count.TTL })},
+
+	{"API_KEY", "", "", setString(func(c *Config) *string { return &c.APIKey })},
+	{"API_KEY_FILE", "api-key-file", "file containing the API key", setFromFile(func(c *Config) *string { return &c.APIKey })},
+}
+
+func setString(ptr func(*Config) *string) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		*ptr(c) = v
+		return nil
+	}
+}
+
+func setStringSlice(ptr func(*Config) *[]string) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		var items []string
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		*ptr(c) = items
+		return nil
+	}
+}
+
+func setInt(ptr func(*Config) *int) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", v)
+		}
+		*ptr(c) = n
+		return nil
+	}
+}
+
+func setBool(ptr func(*Config) *bool) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", v)
+		}
+		*ptr(c) = b
+		return nil
+	}
+}
+
+func setDuration(ptr func(*Config) *time.Duration) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", v)
+		}
+		*ptr(c) = d
+		return nil
+	}
+}
+
+func setDurationMap(ptr func(*Config) *map[string]time.Duration) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		m := make(map[string]time.Duration)
+		for _, pair := range strings.Split(v, ",") {
+			key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if !ok || key == "" {
+				return fmt.Errorf("invalid entry %q, expected name=duration", pair)
+			}
+			d, err := time.ParseDuration(val)
+			if err != nil {
+				return fmt.Errorf("invalid duration %q for %s", val, key)
+			}
+			m[key] = d
+		}
+		*ptr(c) = m
+		return nil
+	}
+}
+
+func setFromFile(ptr func(*Config) *string) func(*Config, string) error {
+	return func(c *Config, path string) error {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		*ptr(c) = strings.TrimRight(string(data), "\r\n")
+		return nil
+	}
+}
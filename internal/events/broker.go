@@ -0,0 +1,107 @@
+package events
+
+import (
+	"context"
+	"log"
+	"practice4/practice-4/internal/repository"
+	"practice4/practice-4/pkg/modules"
+	"sync"
+	"time"
+)
+
+const subscriberBuffer = 64
+
+// Broker fans user changes from a ChangeFeed out to in-process subscribers.
+// Whenever a subscriber could have missed a change — it fell behind, or the
+// feed reconnected — its channel is closed instead, so the client reconnects
+// and catches up from the audit log.
+type Broker struct {
+	feed repository.ChangeFeed
+
+	mu     sync.Mutex
+	subs   map[chan modules.AuditLog]struct{}
+	closed bool
+}
+
+func NewBroker(feed repository.ChangeFeed) *Broker {
+	return &Broker{feed: feed, subs: map[chan modules.AuditLog]struct{}{}}
+}
+
+// Run listens to the feed until ctx is done, reconnecting with backoff.
+func (b *Broker) Run(ctx context.Context) {
+	delay := time.Second
+	for {
+		start := time.Now()
+		err := b.feed.ListenChanges(ctx, b.publish)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("user change feed: %v", err)
+		b.dropAll()
+
+		if time.Since(start) > maxBackoff {
+			delay = time.Second
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxBackoff)
+	}
+}
+
+// Subscribe returns a channel of changes and a function to stop receiving
+// them. The channel is closed when the subscriber must resynchronise or the
+// broker shuts down.
+func (b *Broker) Subscribe() (<-chan modules.AuditLog, func()) {
+	ch := make(chan modules.AuditLog, subscriberBuffer)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	b.subs[ch] = struct{}{}
+	return ch, func() { b.drop(ch) }
+}
+
+// Close ends every subscription and refuses new ones, letting streaming
+// requests finish during server shutdown.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+	b.dropAll()
+}
+
+func (b *Broker) publish(entry modules.AuditLog) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		select {
+		case ch <- entry:
+		default:
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+func (b *Broker) drop(ch chan modules.AuditLog) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[ch]; ok {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
+
+func (b *Broker) dropAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
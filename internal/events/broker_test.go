@@ -0,0 +1,119 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"practice4/practice-4/pkg/modules"
+)
+
+// chanFeed hands the broker whatever is sent on entries, and fails when
+// entries is closed.
+type chanFeed struct {
+	entries chan modules.AuditLog
+}
+
+func (f chanFeed) ListenChanges(ctx context.Context, handle func(modules.AuditLog)) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case e, ok := <-f.entries:
+			if !ok {
+				return errors.New("feed closed")
+			}
+			handle(e)
+		}
+	}
+}
+
+// receive returns the next entry on ch, or ok false once ch is closed.
+func receive(t *testing.T, ch <-chan modules.AuditLog) (modules.AuditLog, bool) {
+	t.Helper()
+	select {
+	case e, ok := <-ch:
+		return e, ok
+	case <-time.After(time.Second):
+		t.Fatal("no entry and no close within a second")
+		return modules.AuditLog{}, false
+	}
+}
+
+func TestBrokerFansOutInOrder(t *testing.T) {
+	feed := chanFeed{entries: make(chan modules.AuditLog)}
+	b := NewBroker(feed)
+	first, unsubscribe := b.Subscribe()
+	defer unsubscribe()
+	second, unsubscribe := b.Subscribe()
+	defer unsubscribe()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go b.Run(ctx)
+
+	for seq := int64(1); seq <= 3; seq++ {
+		feed.entries <- modules.AuditLog{Seq: seq}
+	}
+	for _, ch := range []<-chan modules.AuditLog{first, second} {
+		for want := int64(1); want <= 3; want++ {
+			if e, ok := receive(t, ch); !ok || e.Seq != want {
+				t.Fatalf("received seq %d (open %t), want %d", e.Seq, ok, want)
+			}
+		}
+	}
+}
+
+func TestBrokerDropsSlowSubscribers(t *testing.T) {
+	b := NewBroker(nil)
+	slow, _ := b.Subscribe()
+	fast, unsubscribe := b.Subscribe()
+	defer unsubscribe()
+
+	for seq := int64(1); seq <= subscriberBuffer+1; seq++ {
+		b.publish(modules.AuditLog{Seq: seq})
+		if e, ok := receive(t, fast); !ok || e.Seq != seq {
+			t.Fatalf("fast subscriber received seq %d (open %t), want %d", e.Seq, ok, seq)
+		}
+	}
+	for want := int64(1); want <= subscriberBuffer; want++ {
+		if e, ok := receive(t, slow); !ok || e.Seq != want {
+			t.Fatalf("slow subscriber received seq %d (open %t), want %d", e.Seq, ok, want)
+		}
+	}
+	if e, ok := receive(t, slow); ok {
+		t.Fatalf("slow subscriber received seq %d, want its channel closed", e.Seq)
+	}
+}
+
+func TestBrokerDropsSubscribersWhenFeedFails(t *testing.T) {
+	feed := chanFeed{entries: make(chan modules.AuditLog)}
+	b := NewBroker(feed)
+	ch, unsubscribe := b.Subscribe()
+	defer unsubscribe()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go b.Run(ctx)
+
+	close(feed.entries)
+	if _, ok := receive(t, ch); ok {
+		t.Fatal("subscriber kept receiving after the feed failed")
+	}
+}
+
+func TestBrokerClose(t *testing.T) {
+	b := NewBroker(nil)
+	ch, unsubscribe := b.Subscribe()
+	b.Close()
+	if _, ok := receive(t, ch); ok {
+		t.Fatal("subscription survived Close")
+	}
+	unsubscribe()
+
+	late, _ := b.Subscribe()
+	if _, ok := receive(t, late); ok {
+		t.Fatal("Subscribe after Close returned an open channel")
+	}
+}
@@ -0,0 +1,132 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"practice4/practice-4/pkg/modules"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// EventPublisher delivers outbox events to other services. Delivery is at
+// least once, so consumers should deduplicate on the event ID.
+type EventPublisher interface {
+	Publish(ctx context.Context, event modules.Event) error
+}
+
+// NewPublisher returns the publisher selected by cfg.Publisher. The caller
+// closes it when it implements io.Closer.
+func NewPublisher(cfg *modules.EventsConfig) (EventPublisher, error) {
+	switch cfg.Publisher {
+	case "stdout":
+		return NewWriterPublisher(os.Stdout), nil
+	case "file":
+		return NewFilePublisher(cfg.File)
+	case "webhook":
+		return NewWebhookPublisher(cfg.WebhookURL, cfg.WebhookTimeout), nil
+	default:
+		return nil, fmt.Errorf("unknown event publisher %q", cfg.Publisher)
+	}
+}
+
+// Multi publishes each event to every publisher in turn, failing on the
+// first error; the relay then retries the event on all of them.
+type Multi []EventPublisher
+
+func (m Multi) Publish(ctx context.Context, event modules.Event) error {
+	for _, p := range m {
+		if err := p.Publish(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriterPublisher writes each event to w as a line of JSON.
+type WriterPublisher struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewWriterPublisher(w io.Writer) *WriterPublisher {
+	return &WriterPublisher{w: w}
+}
+
+func (p *WriterPublisher) Publish(_ context.Context, event modules.Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err = p.w.Write(append(line, '\n'))
+	return err
+}
+
+// FilePublisher appends events to a file as JSON lines, syncing after each
+// one so that a published event survives a crash.
+type FilePublisher struct {
+	WriterPublisher
+	f *os.File
+}
+
+func NewFilePublisher(path string) (*FilePublisher, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open event file: %w", err)
+	}
+	return &FilePublisher{WriterPublisher: WriterPublisher{w: f}, f: f}, nil
+}
+
+func (p *FilePublisher) Publish(ctx context.Context, event modules.Event) error {
+	if err := p.WriterPublisher.Publish(ctx, event); err != nil {
+		return err
+	}
+	return p.f.Sync()
+}
+
+func (p *FilePublisher) Close() error {
+	return p.f.Close()
+}
+
+// WebhookPublisher POSTs each event as JSON to a URL; any status outside 2xx
+// counts as a failure.
+type WebhookPublisher struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookPublisher(url string, timeout time.Duration) *WebhookPublisher {
+	return &WebhookPublisher{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+func (p *WebhookPublisher) Publish(ctx context.Context, event modules.Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-ID", strconv.FormatInt(event.ID, 10))
+	req.Header.Set("X-Event-Type", event.Type)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
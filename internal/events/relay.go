@@ -0,0 +1,107 @@
+package events
+
+import (
+	"context"
+	"log"
+	"practice4/practice-4/internal/repository"
+	"practice4/practice-4/pkg/modules"
+	"time"
+)
+
+const (
+	maxBackoff = time.Minute
+	// claimLease is how long a claimed batch is kept from other relays.
+	// Events still unpublished when it runs out fall due again.
+	claimLease = 2 * time.Minute
+	// recordMargin is the part of the lease kept for recording outcomes.
+	recordMargin = 10 * time.Second
+)
+
+// Relay moves events from the outbox to a publisher.
+type Relay struct {
+	repo repository.OutboxRepository
+	pub  EventPublisher
+	cfg  modules.EventsConfig
+}
+
+func NewRelay(repo repository.OutboxRepository, pub EventPublisher, cfg modules.EventsConfig) *Relay {
+	return &Relay{repo: repo, pub: pub, cfg: cfg}
+}
+
+// Run polls the outbox until ctx is done. A full batch is followed by the
+// next one straight away.
+func (r *Relay) Run(ctx context.Context) {
+	for {
+		n, err := r.relay(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Printf("outbox relay: %v", err)
+		}
+		if err == nil && n == r.cfg.BatchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(r.cfg.PollInterval):
+		}
+	}
+}
+
+// relay claims a batch of due events and publishes them in order. Nothing
+// is locked while publishing. An event that fails is retried after a backoff
+// without holding back the others, and is dead after cfg.MaxAttempts. It
+// returns how many events were claimed.
+func (r *Relay) relay(ctx context.Context) (int, error) {
+	due, err := r.repo.ClaimDue(ctx, r.cfg.BatchSize, claimLease)
+	if err != nil {
+		return 0, err
+	}
+	deadline := time.Now().Add(claimLease - recordMargin)
+	for i := range due {
+		if time.Now().After(deadline) {
+			break
+		}
+		event := &due[i]
+		publishCtx, cancel := context.WithDeadline(ctx, deadline)
+		err := r.pub.Publish(publishCtx, *event)
+		cancel()
+		if ctx.Err() != nil {
+			// Shutting down; the lease returns the rest to the outbox.
+			break
+		}
+		r.record(event, err)
+		if err := r.repo.RecordAttempt(ctx, event); err != nil {
+			log.Printf("outbox event %d: record attempt: %v", event.ID, err)
+		}
+	}
+	return len(due), nil
+}
+
+func (r *Relay) record(event *modules.Event, err error) {
+	event.Attempts++
+	if err == nil {
+		event.Published = true
+		event.LastError = ""
+		return
+	}
+
+	event.LastError = err.Error()
+	if event.Attempts >= r.cfg.MaxAttempts {
+		event.Dead = true
+		log.Printf("outbox event %d (%s) is dead after %d attempts: %v", event.ID, event.Type, event.Attempts, err)
+		return
+	}
+	log.Printf("outbox event %d (%s): attempt %d failed: %v", event.ID, event.Type, event.Attempts, err)
+	event.NextAttemptAt = time.Now().Add(r.backoff(event.Attempts))
+}
+
+// backoff doubles from cfg.PollInterval per attempt up to maxBackoff.
+func (r *Relay) backoff(attempts int) time.Duration {
+	if shift := attempts - 1; shift < 32 {
+		return min(r.cfg.PollInterval<<shift, maxBackoff)
+	}
+	return maxBackoff
+}
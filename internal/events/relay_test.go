@@ -0,0 +1,140 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"practice4/practice-4/pkg/modules"
+)
+
+// memOutbox is an in-memory OutboxRepository.
+type memOutbox struct {
+	mu     sync.Mutex
+	events []modules.Event
+}
+
+func (m *memOutbox) add(n int) {
+	for i := 0; i < n; i++ {
+		id := int64(len(m.events) + 1)
+		m.events = append(m.events, modules.Event{ID: id, Type: modules.EventUserCreated, AggregateID: id, NextAttemptAt: time.Now()})
+	}
+}
+
+func (m *memOutbox) get(id int64) modules.Event {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.events[id-1]
+}
+
+// makeDue pretends every backoff has elapsed.
+func (m *memOutbox) makeDue() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.events {
+		m.events[i].NextAttemptAt = time.Now()
+	}
+}
+
+func (m *memOutbox) ClaimDue(_ context.Context, limit int, lease time.Duration) ([]modules.Event, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var due []modules.Event
+	for i := range m.events {
+		e := &m.events[i]
+		if len(due) == limit || e.Published || e.Dead || e.NextAttemptAt.After(time.Now()) {
+			continue
+		}
+		e.NextAttemptAt = time.Now().Add(lease)
+		due = append(due, *e)
+	}
+	return due, nil
+}
+
+func (m *memOutbox) RecordAttempt(_ context.Context, e *modules.Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events[e.ID-1] = *e
+	return nil
+}
+
+// failing publishes every event except those it was told to reject.
+type failing struct {
+	reject    map[int64]bool
+	published []int64
+}
+
+func (p *failing) Publish(_ context.Context, e modules.Event) error {
+	if p.reject[e.ID] {
+		return errors.New("rejected")
+	}
+	p.published = append(p.published, e.ID)
+	return nil
+}
+
+func testRelay(repo *memOutbox, pub EventPublisher) *Relay {
+	return NewRelay(repo, pub, modules.EventsConfig{PollInterval: time.Second, BatchSize: 10, MaxAttempts: 3})
+}
+
+func TestRelayFailureDoesNotBlockLaterEvents(t *testing.T) {
+	repo := &memOutbox{}
+	repo.add(3)
+	pub := &failing{reject: map[int64]bool{1: true}}
+
+	n, err := testRelay(repo, pub).relay(context.Background())
+	if err != nil || n != 3 {
+		t.Fatalf("relay = %d, %v; want 3 claimed", n, err)
+	}
+	if len(pub.published) != 2 || pub.published[0] != 2 || pub.published[1] != 3 {
+		t.Fatalf("published %v, want [2 3]", pub.published)
+	}
+	first := repo.get(1)
+	if first.Published || first.Dead || first.Attempts != 1 || first.LastError != "rejected" {
+		t.Fatalf("failed event = %+v, want one recorded failure", first)
+	}
+	if !first.NextAttemptAt.After(time.Now()) {
+		t.Fatalf("failed event is due again immediately")
+	}
+	if e := repo.get(2); !e.Published || e.Attempts != 1 {
+		t.Fatalf("event 2 = %+v, want published", e)
+	}
+}
+
+func TestRelayDeadLettersAfterMaxAttempts(t *testing.T) {
+	repo := &memOutbox{}
+	repo.add(1)
+	relay := testRelay(repo, &failing{reject: map[int64]bool{1: true}})
+
+	for i := 0; i < 3; i++ {
+		if n, err := relay.relay(context.Background()); err != nil || n != 1 {
+			t.Fatalf("attempt %d: relay = %d, %v; want 1 claimed", i+1, n, err)
+		}
+		repo.makeDue()
+	}
+	if e := repo.get(1); !e.Dead || e.Published || e.Attempts != 3 {
+		t.Fatalf("event = %+v, want dead after 3 attempts", e)
+	}
+	if n, _ := relay.relay(context.Background()); n != 0 {
+		t.Fatalf("dead event was claimed again")
+	}
+}
+
+func TestRelayBackoff(t *testing.T) {
+	relay := testRelay(&memOutbox{}, &failing{})
+	for _, tc := range []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{4, 8 * time.Second},
+		{7, maxBackoff},
+		{100, maxBackoff},
+	} {
+		if got := relay.backoff(tc.attempts); got != tc.want {
+			t.Errorf("backoff(%d) = %v, want %v", tc.attempts, got, tc.want)
+		}
+	}
+}
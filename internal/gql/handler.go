@@ -0,0 +1,122 @@
+package gql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"practice4/practice-4/internal/usecase"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+const maxBodyBytes = 1 << 20
+
+type request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+	// Extensions is part of the GraphQL over HTTP request format; it is
+	// accepted so clients that send it are not rejected, and ignored.
+	Extensions map[string]any `json:"extensions"`
+}
+
+// Handler serves GraphQL over HTTP: POST with a JSON body, or GET with
+// query, operationName and variables parameters for read-only queries.
+type Handler struct {
+	schema graphql.Schema
+	uc     usecase.UserUsecase
+}
+
+func NewHandler(uc usecase.UserUsecase) (*Handler, error) {
+	schema, err := NewSchema(uc)
+	if err != nil {
+		return nil, err
+	}
+	return &Handler{schema: schema, uc: uc}, nil
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req request
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		req.Query = q.Get("query")
+		req.OperationName = q.Get("operationName")
+		if v := q.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				writeErrors(w, http.StatusBadRequest, "variables must be a JSON object")
+				return
+			}
+		}
+	case http.MethodPost:
+		dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&req); err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				writeErrors(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("body must not be larger than %d bytes", maxErr.Limit))
+				return
+			}
+			if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+				writeErrors(w, http.StatusBadRequest, "unknown field "+field)
+				return
+			}
+			writeErrors(w, http.StatusBadRequest, "body must be a JSON object with a query")
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeErrors(w, http.StatusMethodNotAllowed, "use GET or POST")
+		return
+	}
+	if req.Query == "" {
+		writeErrors(w, http.StatusBadRequest, "query is required")
+		return
+	}
+
+	if r.Method == http.MethodGet && isMutation(req.Query, req.OperationName) {
+		writeErrors(w, http.StatusMethodNotAllowed, "mutations must use POST")
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), loadersKey{}, newLoaders(h.uc))
+	result := graphql.Do(graphql.Params{
+		Schema:         h.schema,
+		RequestString:  req.Query,
+		OperationName:  req.OperationName,
+		VariableValues: req.Variables,
+		Context:        ctx,
+	})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func writeErrors(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{"errors": []map[string]string{{"message": msg}}})
+}
+
+// isMutation reports whether the selected operation is a mutation. Parse
+// errors are left for execution to report.
+func isMutation(query, operationName string) bool {
+	doc, err := parser.Parse(parser.ParseParams{Source: query})
+	if err != nil {
+		return false
+	}
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if operationName == "" || (op.Name != nil && op.Name.Value == operationName) {
+			return op.Operation == ast.OperationTypeMutation
+		}
+	}
+	return false
+}
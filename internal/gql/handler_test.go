@@ -0,0 +1,197 @@
+package gql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"practice4/practice-4/internal/usecase"
+	"practice4/practice-4/pkg/modules"
+)
+
+// countingUsers is a UserUsecase over n users that counts the calls made to
+// it.
+type countingUsers struct {
+	usecase.UserUsecase
+	n int
+
+	mu          sync.Mutex
+	batches     [][]int64
+	creates     int
+	getAllCalls int
+}
+
+func (c *countingUsers) GetAll(_ context.Context, _ modules.UserFilter, limit, offset int64) (*modules.PaginatedUsers, error) {
+	c.mu.Lock()
+	c.getAllCalls++
+	c.mu.Unlock()
+	page := &modules.PaginatedUsers{Total: int64(c.n), Limit: limit, Offset: offset}
+	for id := offset + 1; id <= int64(c.n) && int64(len(page.Users)) < limit; id++ {
+		page.Users = append(page.Users, modules.User{ID: id, Name: fmt.Sprintf("user %d", id), CreatedAt: time.Unix(0, 0)})
+	}
+	return page, nil
+}
+
+func (c *countingUsers) AuditLogsByUsers(_ context.Context, userIDs []int64, perUser int64) (map[int64][]modules.AuditLog, error) {
+	c.mu.Lock()
+	c.batches = append(c.batches, userIDs)
+	c.mu.Unlock()
+	byUser := make(map[int64][]modules.AuditLog, len(userIDs))
+	for _, id := range userIDs {
+		byUser[id] = []modules.AuditLog{{ID: id, UserID: id, Action: "create", CreatedAt: time.Unix(0, 0)}}
+	}
+	return byUser, nil
+}
+
+func (c *countingUsers) Create(context.Context, *modules.User) (int64, error) {
+	c.mu.Lock()
+	c.creates++
+	c.mu.Unlock()
+	return 1, nil
+}
+
+func (c *countingUsers) GetByID(_ context.Context, id int64) (*modules.User, error) {
+	return &modules.User{ID: id, Name: "Bob", Email: "bob@example.com", CreatedAt: time.Unix(0, 0)}, nil
+}
+
+type response struct {
+	Data   map[string]any `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func serve(t *testing.T, uc usecase.UserUsecase, req *http.Request) (int, response) {
+	t.Helper()
+	h, err := NewHandler(uc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	var resp response
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("body %s: %v", rec.Body, err)
+	}
+	return rec.Code, resp
+}
+
+func post(t *testing.T, body string) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func get(t *testing.T, query string) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, "/graphql?query="+url.QueryEscape(query), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func TestAuditLogsAreBatched(t *testing.T) {
+	const n = 25
+	uc := &countingUsers{n: n}
+	query := fmt.Sprintf(`{ users(first: %d) { edges { node { id auditLogs(last: 3) { action } } } } }`, n)
+	status, resp := serve(t, uc, get(t, query))
+	if status != http.StatusOK || len(resp.Errors) > 0 {
+		t.Fatalf("status %d, errors %+v", status, resp.Errors)
+	}
+
+	edges := resp.Data["users"].(map[string]any)["edges"].([]any)
+	if len(edges) != n {
+		t.Fatalf("got %d users, want %d", len(edges), n)
+	}
+	for _, e := range edges {
+		if logs := e.(map[string]any)["node"].(map[string]any)["auditLogs"].([]any); len(logs) != 1 {
+			t.Errorf("user %v has %d audit logs, want 1", e, len(logs))
+		}
+	}
+	if len(uc.batches) != 1 || len(uc.batches[0]) != n {
+		t.Errorf("AuditLogsByUsers called with %v, want one call for all %d users", uc.batches, n)
+	}
+	if uc.getAllCalls != 1 {
+		t.Errorf("GetAll called %d times, want 1", uc.getAllCalls)
+	}
+}
+
+func TestRequests(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		req    func(t *testing.T) *http.Request
+		status int
+		err    string
+	}{
+		{"mutation over GET", func(t *testing.T) *http.Request {
+			return get(t, `mutation { createUser(input: {name: "Bob", email: "bob@example.com"}) { id } }`)
+		}, http.StatusMethodNotAllowed, "mutations must use POST"},
+		{"named mutation over GET", func(t *testing.T) *http.Request {
+			req := get(t, `query Q { user(id: 1) { id } } mutation M { deleteUser(id: 1) }`)
+			req.URL.RawQuery += "&operationName=M"
+			return req
+		}, http.StatusMethodNotAllowed, "mutations must use POST"},
+		{"unknown POST field", func(t *testing.T) *http.Request {
+			return post(t, `{"query": "{ users { totalCount } }", "varibles": {}}`)
+		}, http.StatusBadRequest, `unknown field "varibles"`},
+		{"malformed POST body", func(t *testing.T) *http.Request {
+			return post(t, `{"query": `)
+		}, http.StatusBadRequest, "body must be a JSON object with a query"},
+		{"missing query", func(t *testing.T) *http.Request {
+			return post(t, `{"variables": {}}`)
+		}, http.StatusBadRequest, "query is required"},
+		{"bad variables", func(t *testing.T) *http.Request {
+			req := get(t, `{ users { totalCount } }`)
+			req.URL.RawQuery += "&variables=%5B%5D"
+			return req
+		}, http.StatusBadRequest, "variables must be a JSON object"},
+		{"other method", func(t *testing.T) *http.Request {
+			req, err := http.NewRequest(http.MethodPut, "/graphql", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return req
+		}, http.StatusMethodNotAllowed, "use GET or POST"},
+		{"extensions accepted", func(t *testing.T) *http.Request {
+			return post(t, `{"query": "{ users { totalCount } }", "extensions": {"trace": true}}`)
+		}, http.StatusOK, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			uc := &countingUsers{n: 3}
+			status, resp := serve(t, uc, tc.req(t))
+			if status != tc.status {
+				t.Errorf("status = %d, want %d", status, tc.status)
+			}
+			if tc.err == "" {
+				if len(resp.Errors) > 0 {
+					t.Errorf("errors %+v, want none", resp.Errors)
+				}
+			} else if len(resp.Errors) != 1 || resp.Errors[0].Message != tc.err {
+				t.Errorf("errors %+v, want %q", resp.Errors, tc.err)
+			}
+			if uc.creates != 0 {
+				t.Error("a rejected request created a user")
+			}
+		})
+	}
+}
+
+func TestMutationOverPOST(t *testing.T) {
+	uc := &countingUsers{}
+	status, resp := serve(t, uc, post(t, `{"query": "mutation { createUser(input: {name: \"Bob\", email: \"bob@example.com\"}) { id } }"}`))
+	if status != http.StatusOK || len(resp.Errors) > 0 || uc.creates != 1 {
+		t.Errorf("status %d, errors %+v, %d creates; want one user created", status, resp.Errors, uc.creates)
+	}
+}
@@ -0,0 +1,52 @@
+package gql
+
+import (
+	"context"
+	"practice4/practice-4/internal/usecase"
+	"practice4/practice-4/pkg/modules"
+	"sync"
+
+	"github.com/graph-gophers/dataloader/v7"
+)
+
+type loadersKey struct{}
+
+// loaders holds the per-request dataloaders. Audit log loaders are keyed by
+// the requested length, since one batch query serves one length.
+type loaders struct {
+	uc usecase.UserUsecase
+
+	mu     sync.Mutex
+	audits map[int64]*dataloader.Loader[int64, []modules.AuditLog]
+}
+
+func newLoaders(uc usecase.UserUsecase) *loaders {
+	return &loaders{uc: uc, audits: map[int64]*dataloader.Loader[int64, []modules.AuditLog]{}}
+}
+
+func (l *loaders) auditLogs(last int64) *dataloader.Loader[int64, []modules.AuditLog] {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if loader, ok := l.audits[last]; ok {
+		return loader
+	}
+	loader := dataloader.NewBatchedLoader(func(ctx context.Context, userIDs []int64) []*dataloader.Result[[]modules.AuditLog] {
+		results := make([]*dataloader.Result[[]modules.AuditLog], len(userIDs))
+		byUser, err := l.uc.AuditLogsByUsers(ctx, userIDs, last)
+		for i, id := range userIDs {
+			if err != nil {
+				results[i] = &dataloader.Result[[]modules.AuditLog]{Error: err}
+				continue
+			}
+			logs := byUser[id]
+			if logs == nil {
+				logs = []modules.AuditLog{}
+			}
+			results[i] = &dataloader.Result[[]modules.AuditLog]{Data: logs}
+		}
+		return results
+	})
+	l.audits[last] = loader
+	return loader
+}
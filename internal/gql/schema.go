@@ -0,0 +1,349 @@
+package gql
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"practice4/practice-4/internal/usecase"
+	"practice4/practice-4/pkg/apperrors"
+	"practice4/practice-4/pkg/modules"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+)
+
+const (
+	defaultPageSize = 10
+	maxPageSize     = 100
+	defaultAuditLen = 20
+)
+
+type resolver struct {
+	uc usecase.UserUsecase
+}
+
+// NewSchema builds the GraphQL schema over uc.
+func NewSchema(uc usecase.UserUsecase) (graphql.Schema, error) {
+	r := &resolver{uc: uc}
+
+	auditLogType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "AuditLog",
+		Fields: graphql.Fields{
+			"id":        &graphql.Field{Type: graphql.NewNonNull(graphql.ID), Resolve: auditField(func(l modules.AuditLog) any { return l.ID })},
+			"userId":    &graphql.Field{Type: graphql.NewNonNull(graphql.ID), Resolve: auditField(func(l modules.AuditLog) any { return l.UserID })},
+			"action":    &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: auditField(func(l modules.AuditLog) any { return l.Action })},
+			"createdAt": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime), Resolve: auditField(func(l modules.AuditLog) any { return l.CreatedAt })},
+		},
+	})
+
+	userType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.Fields{
+			"id":        &graphql.Field{Type: graphql.NewNonNull(graphql.ID), Resolve: userField(func(u *modules.User) any { return u.ID })},
+			"name":      &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: userField(func(u *modules.User) any { return u.Name })},
+			"email":     &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: userField(func(u *modules.User) any { return u.Email })},
+			"createdAt": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime), Resolve: userField(func(u *modules.User) any { return u.CreatedAt })},
+			"deletedAt": &graphql.Field{Type: graphql.DateTime, Resolve: userField(func(u *modules.User) any {
+				if u.DeletedAt == nil {
+					return nil
+				}
+				return *u.DeletedAt
+			})},
+			"auditLogs": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(auditLogType))),
+				Description: "The most recent audit log entries, oldest first.",
+				Args: graphql.FieldConfigArgument{
+					"last": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultAuditLen},
+				},
+				Resolve: r.auditLogs,
+			},
+		},
+	})
+
+	pageInfoType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "PageInfo",
+		Fields: graphql.Fields{
+			"hasNextPage":     &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"hasPreviousPage": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"startCursor":     &graphql.Field{Type: graphql.String},
+			"endCursor":       &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	edgeType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "UserEdge",
+		Fields: graphql.Fields{
+			"cursor": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"node":   &graphql.Field{Type: graphql.NewNonNull(userType)},
+		},
+	})
+
+	connectionType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "UserConnection",
+		Fields: graphql.Fields{
+			"edges":      &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(edgeType)))},
+			"pageInfo":   &graphql.Field{Type: graphql.NewNonNull(pageInfoType)},
+			"totalCount": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"totalIsEstimate": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.Boolean),
+				Description: "Whether totalCount is approximate or possibly stale.",
+			},
+		},
+	})
+
+	filterType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:        "UserFilter",
+		Description: "Case-insensitive substring matches; omitted fields match everything.",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"name":  &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"email": &graphql.InputObjectFieldConfig{Type: graphql.String},
+		},
+	})
+
+	inputType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "UserInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"name":  &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"email": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"user": &graphql.Field{
+				Type:    userType,
+				Args:    graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)}},
+				Resolve: r.user,
+			},
+			"users": &graphql.Field{
+				Type: graphql.NewNonNull(connectionType),
+				Args: graphql.FieldConfigArgument{
+					"first":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultPageSize},
+					"after":  &graphql.ArgumentConfig{Type: graphql.String},
+					"filter": &graphql.ArgumentConfig{Type: filterType},
+				},
+				Resolve: r.users,
+			},
+		},
+	})
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createUser": &graphql.Field{
+				Type: graphql.NewNonNull(userType),
+				Args: graphql.FieldConfigArgument{
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(inputType)},
+					"audit": &graphql.ArgumentConfig{Type: graphql.Boolean, DefaultValue: false},
+				},
+				Resolve: r.createUser,
+			},
+			"updateUser": &graphql.Field{
+				Type: graphql.NewNonNull(userType),
+				Args: graphql.FieldConfigArgument{
+					"id":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(inputType)},
+				},
+				Resolve: r.updateUser,
+			},
+			"deleteUser": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.Boolean),
+				Args:    graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)}},
+				Resolve: r.deleteUser,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query, Mutation: mutation})
+}
+
+func userField(get func(*modules.User) any) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (any, error) {
+		return get(p.Source.(*modules.User)), nil
+	}
+}
+
+func auditField(get func(modules.AuditLog) any) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (any, error) {
+		return get(p.Source.(modules.AuditLog)), nil
+	}
+}
+
+func (r *resolver) user(p graphql.ResolveParams) (any, error) {
+	id, err := idArg(p.Args["id"])
+	if err != nil {
+		return nil, err
+	}
+	user, err := r.uc.GetByID(p.Context, id)
+	if errors.Is(err, apperrors.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, toError(err)
+	}
+	return user, nil
+}
+
+func (r *resolver) users(p graphql.ResolveParams) (any, error) {
+	first, _ := p.Args["first"].(int)
+	if first <= 0 {
+		first = defaultPageSize
+	}
+	if first > maxPageSize {
+		first = maxPageSize
+	}
+	var offset int64
+	if after, ok := p.Args["after"].(string); ok && after != "" {
+		n, err := decodeCursor(after)
+		if err != nil {
+			return nil, err
+		}
+		offset = n + 1
+	}
+	var filter modules.UserFilter
+	if f, ok := p.Args["filter"].(map[string]any); ok {
+		filter.Name, _ = f["name"].(string)
+		filter.Email, _ = f["email"].(string)
+	}
+
+	page, err := r.uc.GetAll(p.Context, filter, int64(first), offset)
+	if err != nil {
+		return nil, toError(err)
+	}
+
+	edges := make([]map[string]any, len(page.Users))
+	for i := range page.Users {
+		edges[i] = map[string]any{"cursor": encodeCursor(offset + int64(i)), "node": &page.Users[i]}
+	}
+	pageInfo := map[string]any{
+		"hasNextPage": offset+int64(len(page.Users)) < page.Total ||
+			page.TotalIsEstimate && int64(len(page.Users)) == page.Limit,
+		"hasPreviousPage": offset > 0,
+	}
+	if len(edges) > 0 {
+		pageInfo["startCursor"] = edges[0]["cursor"]
+		pageInfo["endCursor"] = edges[len(edges)-1]["cursor"]
+	}
+	return map[string]any{"edges": edges, "pageInfo": pageInfo, "totalCount": page.Total, "totalIsEstimate": page.TotalIsEstimate}, nil
+}
+
+// auditLogs defers to the request's loader so that the entries of every
+// user in a result are fetched in one batch.
+func (r *resolver) auditLogs(p graphql.ResolveParams) (any, error) {
+	user := p.Source.(*modules.User)
+	last, _ := p.Args["last"].(int)
+	loaders, ok := p.Context.Value(loadersKey{}).(*loaders)
+	if !ok {
+		return nil, errors.New("audit log loader missing from context")
+	}
+	thunk := loaders.auditLogs(int64(last)).Load(p.Context, user.ID)
+	return func() (any, error) {
+		logs, err := thunk()
+		if err != nil {
+			return nil, toError(err)
+		}
+		return logs, nil
+	}, nil
+}
+
+func (r *resolver) createUser(p graphql.ResolveParams) (any, error) {
+	input := p.Args["input"].(map[string]any)
+	user := &modules.User{Name: input["name"].(string), Email: input["email"].(string)}
+	create := r.uc.Create
+	if audit, _ := p.Args["audit"].(bool); audit {
+		create = r.uc.CreateUserWithAudit
+	}
+	id, err := create(p.Context, user)
+	if err != nil {
+		return nil, toError(err)
+	}
+	created, err := r.uc.GetByID(p.Context, id)
+	if err != nil {
+		return nil, toError(err)
+	}
+	return created, nil
+}
+
+func (r *resolver) updateUser(p graphql.ResolveParams) (any, error) {
+	id, err := idArg(p.Args["id"])
+	if err != nil {
+		return nil, err
+	}
+	input := p.Args["input"].(map[string]any)
+	if err := r.uc.Update(p.Context, &modules.User{ID: id, Name: input["name"].(string), Email: input["email"].(string)}); err != nil {
+		return nil, toError(err)
+	}
+	updated, err := r.uc.GetByID(p.Context, id)
+	if err != nil {
+		return nil, toError(err)
+	}
+	return updated, nil
+}
+
+func (r *resolver) deleteUser(p graphql.ResolveParams) (any, error) {
+	id, err := idArg(p.Args["id"])
+	if err != nil {
+		return nil, err
+	}
+	if err := r.uc.Delete(p.Context, id); err != nil {
+		return nil, toError(err)
+	}
+	return true, nil
+}
+
+func idArg(v any) (int64, error) {
+	s, _ := v.(string)
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid user ID %q", s)
+	}
+	return id, nil
+}
+
+// Cursors are opaque to clients but simply encode the row offset.
+func encodeCursor(offset int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("offset:" + strconv.FormatInt(offset, 10)))
+}
+
+func decodeCursor(cursor string) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err == nil {
+		if n, ok := strings.CutPrefix(string(raw), "offset:"); ok {
+			if offset, err := strconv.ParseInt(n, 10, 64); err == nil && offset >= 0 {
+				return offset, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("invalid cursor %q", cursor)
+}
+
+// codedError carries the error class to clients in the "code" extension.
+type codedError struct {
+	msg  string
+	code string
+}
+
+func (e *codedError) Error() string { return e.msg }
+
+func (e *codedError) Extensions() map[string]any {
+	return map[string]any{"code": e.code}
+}
+
+func toError(err error) error {
+	switch {
+	case errors.Is(err, apperrors.ErrNotFound):
+		return &codedError{"user not found", "NOT_FOUND"}
+	case errors.Is(err, apperrors.ErrValidation):
+		return &codedError{"invalid input", "BAD_USER_INPUT"}
+	case errors.Is(err, apperrors.ErrConflict):
+		return &codedError{"user already exists", "CONFLICT"}
+	case errors.Is(err, apperrors.ErrTimeout):
+		return &codedError{"request timed out", "TIMEOUT"}
+	case errors.Is(err, apperrors.ErrTransient):
+		return &codedError{"conflicting concurrent change, retry the request", "TRANSIENT"}
+	default:
+		return &codedError{"internal server error", "INTERNAL"}
+	}
+}
@@ -0,0 +1,201 @@
+package grpcapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	usersv1 "practice4/practice-4/api/users/v1"
+	"practice4/practice-4/internal/usecase"
+	"practice4/practice-4/pkg/apperrors"
+	"practice4/practice-4/pkg/modules"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const apiKeyMetadata = "x-api-key"
+
+// Server implements usersv1.UserServiceServer on top of the same usecase
+// as the REST handlers.
+type Server struct {
+	usersv1.UnimplementedUserServiceServer
+	uc usecase.UserUsecase
+}
+
+func NewServer(uc usecase.UserUsecase) *Server {
+	return &Server{uc: uc}
+}
+
+// NewGRPCServer returns a gRPC server with the user service registered and
+// every call authenticated against apiKey.
+func NewGRPCServer(uc usecase.UserUsecase, apiKey string) *grpc.Server {
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(unaryAuth(apiKey)),
+		grpc.ChainStreamInterceptor(streamAuth(apiKey)),
+	)
+	usersv1.RegisterUserServiceServer(srv, NewServer(uc))
+	return srv
+}
+
+func (s *Server) ListUsers(ctx context.Context, req *usersv1.ListUsersRequest) (*usersv1.ListUsersResponse, error) {
+	limit := req.GetLimit()
+	if limit <= 0 {
+		limit = 10
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	offset := req.GetOffset()
+	if offset < 0 {
+		offset = 0
+	}
+
+	page, err := s.uc.GetAll(ctx, modules.UserFilter{}, limit, offset)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	resp := &usersv1.ListUsersResponse{
+		Total:           page.Total,
+		TotalIsEstimate: page.TotalIsEstimate,
+		Limit:           page.Limit,
+		Offset:          page.Offset,
+	}
+	for i := range page.Users {
+		resp.Users = append(resp.Users, toProto(&page.Users[i]))
+	}
+	return resp, nil
+}
+
+func (s *Server) StreamUsers(req *usersv1.StreamUsersRequest, stream usersv1.UserService_StreamUsersServer) error {
+	pageSize := req.GetPageSize()
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 100
+	}
+
+	// Paging by key neither counts nor rescans skipped rows, and does not
+	// skip or repeat users when others are created or deleted meanwhile.
+	ctx := stream.Context()
+	var filter modules.UserFilter
+	for {
+		users, err := s.uc.ListUsers(ctx, filter, pageSize)
+		if err != nil {
+			return toStatus(err)
+		}
+		for i := range users {
+			if err := stream.Send(toProto(&users[i])); err != nil {
+				return err
+			}
+		}
+		if int64(len(users)) < pageSize {
+			return nil
+		}
+		filter.AfterID = users[len(users)-1].ID
+	}
+}
+
+func (s *Server) GetUser(ctx context.Context, req *usersv1.GetUserRequest) (*usersv1.User, error) {
+	user, err := s.uc.GetByID(ctx, req.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toProto(user), nil
+}
+
+func (s *Server) CreateUser(ctx context.Context, req *usersv1.CreateUserRequest) (*usersv1.CreateUserResponse, error) {
+	user := &modules.User{Name: req.GetName(), Email: req.GetEmail()}
+	create := s.uc.Create
+	if req.GetAudit() {
+		create = s.uc.CreateUserWithAudit
+	}
+	id, err := create(ctx, user)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &usersv1.CreateUserResponse{Id: id}, nil
+}
+
+func (s *Server) UpdateUser(ctx context.Context, req *usersv1.UpdateUserRequest) (*emptypb.Empty, error) {
+	if err := s.uc.Update(ctx, &modules.User{ID: req.GetId(), Name: req.GetName(), Email: req.GetEmail()}); err != nil {
+		return nil, toStatus(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) DeleteUser(ctx context.Context, req *usersv1.DeleteUserRequest) (*emptypb.Empty, error) {
+	if err := s.uc.Delete(ctx, req.GetId()); err != nil {
+		return nil, toStatus(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) RestoreUser(ctx context.Context, req *usersv1.RestoreUserRequest) (*emptypb.Empty, error) {
+	if err := s.uc.Restore(ctx, req.GetId()); err != nil {
+		return nil, toStatus(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func toProto(u *modules.User) *usersv1.User {
+	pb := &usersv1.User{
+		Id:        u.ID,
+		Name:      u.Name,
+		Email:     u.Email,
+		CreatedAt: timestamppb.New(u.CreatedAt),
+	}
+	if u.DeletedAt != nil {
+		pb.DeletedAt = timestamppb.New(*u.DeletedAt)
+	}
+	return pb
+}
+
+// toStatus maps apperrors to gRPC codes the way the REST handlers map them
+// to HTTP statuses; unexpected errors are not leaked to the client.
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, apperrors.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, apperrors.ErrValidation):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, apperrors.ErrConflict):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, apperrors.ErrTransient):
+		return status.Error(codes.Unavailable, "Conflicting concurrent change, retry the request")
+	case errors.Is(err, apperrors.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, "Request timed out")
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, "Request canceled")
+	default:
+		return status.Error(codes.Internal, "Internal server error")
+	}
+}
+
+func authorize(ctx context.Context, apiKey string) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	keys := md.Get(apiKeyMetadata)
+	if len(keys) == 0 || subtle.ConstantTimeCompare([]byte(keys[0]), []byte(apiKey)) != 1 {
+		return status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+	return nil
+}
+
+func unaryAuth(apiKey string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := authorize(ctx, apiKey); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func streamAuth(apiKey string) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := authorize(ss.Context(), apiKey); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,179 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	usersv1 "practice4/practice-4/api/users/v1"
+	"practice4/practice-4/internal/usecase"
+	"practice4/practice-4/pkg/apperrors"
+	"practice4/practice-4/pkg/modules"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const apiKey = "test-key"
+
+// fakeUsers is a UserUsecase over users with IDs 1 to n, skipping multiples
+// of 10 as if they had been deleted. GetAll is left unimplemented, so a
+// stream that counts users panics.
+type fakeUsers struct {
+	usecase.UserUsecase
+	n      int64
+	filter []modules.UserFilter
+}
+
+func (f *fakeUsers) ListUsers(_ context.Context, filter modules.UserFilter, limit int64) ([]modules.User, error) {
+	f.filter = append(f.filter, filter)
+	var users []modules.User
+	for id := filter.AfterID + 1; id <= f.n && int64(len(users)) < limit; id++ {
+		if id%10 != 0 {
+			users = append(users, modules.User{ID: id, Name: fmt.Sprintf("user %d", id)})
+		}
+	}
+	return users, nil
+}
+
+func (f *fakeUsers) GetByID(_ context.Context, id int64) (*modules.User, error) {
+	if id != 1 {
+		return nil, apperrors.ErrNotFound
+	}
+	return &modules.User{ID: 1, Name: "Alice", Email: "alice@example.com"}, nil
+}
+
+func (f *fakeUsers) Create(_ context.Context, user *modules.User) (int64, error) {
+	if user.Name == "" || user.Email == "" {
+		return 0, apperrors.ErrValidation
+	}
+	return 2, nil
+}
+
+func dial(t *testing.T, uc usecase.UserUsecase) usersv1.UserServiceClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := NewGRPCServer(uc, apiKey)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return usersv1.NewUserServiceClient(conn)
+}
+
+func withKey(key string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), apiKeyMetadata, key)
+}
+
+func TestAuthentication(t *testing.T) {
+	client := dial(t, &fakeUsers{n: 5})
+	for _, tc := range []struct {
+		name string
+		ctx  context.Context
+	}{
+		{"missing key", context.Background()},
+		{"wrong key", withKey("wrong")},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := client.GetUser(tc.ctx, &usersv1.GetUserRequest{Id: 1}); status.Code(err) != codes.Unauthenticated {
+				t.Errorf("GetUser = %v, want Unauthenticated", err)
+			}
+			stream, err := client.StreamUsers(tc.ctx, &usersv1.StreamUsersRequest{})
+			if err == nil {
+				_, err = stream.Recv()
+			}
+			if status.Code(err) != codes.Unauthenticated {
+				t.Errorf("StreamUsers = %v, want Unauthenticated", err)
+			}
+		})
+	}
+}
+
+func TestUnaryErrors(t *testing.T) {
+	client := dial(t, &fakeUsers{n: 5})
+	ctx := withKey(apiKey)
+
+	if user, err := client.GetUser(ctx, &usersv1.GetUserRequest{Id: 1}); err != nil || user.GetName() != "Alice" {
+		t.Errorf("GetUser(1) = %v, %v; want Alice", user, err)
+	}
+	if _, err := client.GetUser(ctx, &usersv1.GetUserRequest{Id: 2}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetUser(2) = %v, want NotFound", err)
+	}
+	if _, err := client.CreateUser(ctx, &usersv1.CreateUserRequest{Name: "Bob"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("CreateUser without email = %v, want InvalidArgument", err)
+	}
+}
+
+func TestStreamUsersPagesByKey(t *testing.T) {
+	uc := &fakeUsers{n: 250}
+	client := dial(t, uc)
+	stream, err := client.StreamUsers(withKey(apiKey), &usersv1.StreamUsersRequest{PageSize: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var last int64
+	received := 0
+	for {
+		user, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.GetId() <= last {
+			t.Fatalf("received user %d after %d", user.GetId(), last)
+		}
+		last = user.GetId()
+		received++
+	}
+
+	// 250 users less the 25 multiples of 10.
+	if received != 225 {
+		t.Errorf("received %d users, want 225", received)
+	}
+	var afterIDs []int64
+	for _, f := range uc.filter {
+		afterIDs = append(afterIDs, f.AfterID)
+	}
+	if fmt.Sprint(afterIDs) != "[0 111 222]" {
+		t.Errorf("pages after IDs %v, want [0 111 222]", afterIDs)
+	}
+}
+
+func TestToStatus(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		code codes.Code
+	}{
+		{apperrors.ErrNotFound, codes.NotFound},
+		{fmt.Errorf("%w: name is required", apperrors.ErrValidation), codes.InvalidArgument},
+		{apperrors.ErrConflict, codes.AlreadyExists},
+		{apperrors.ErrTransient, codes.Unavailable},
+		{apperrors.ErrTimeout, codes.DeadlineExceeded},
+		{context.DeadlineExceeded, codes.DeadlineExceeded},
+		{context.Canceled, codes.Canceled},
+		{errors.New("pq: password authentication failed"), codes.Internal},
+	} {
+		st, _ := status.FromError(toStatus(tc.err))
+		if st.Code() != tc.code {
+			t.Errorf("toStatus(%v) = %v, want %v", tc.err, st.Code(), tc.code)
+		}
+		if tc.code == codes.Internal && st.Message() != "Internal server error" {
+			t.Errorf("toStatus(%v) leaks %q", tc.err, st.Message())
+		}
+	}
+}
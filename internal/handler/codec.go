@@ -0,0 +1,379 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec encodes response bodies and decodes request bodies for a media type.
+// Decode must reject unknown fields and trailing data where the format allows.
+type Codec interface {
+	Encode(w io.Writer, v any) error
+	Decode(r io.Reader, v any) error
+}
+
+var (
+	codecs = map[string]Codec{}
+	// mediaTypes lists registered media types in preference order, used
+	// when Accept allows several or uses wildcards.
+	mediaTypes []string
+)
+
+func init() {
+	RegisterCodec("application/json", jsonCodec{})
+	RegisterCodec("application/xml", xmlCodec{})
+	RegisterCodec("application/msgpack", msgpackCodec{})
+}
+
+// RegisterCodec makes c available for mediaType. It is not safe to call
+// while the server is handling requests.
+func RegisterCodec(mediaType string, c Codec) {
+	if _, ok := codecs[mediaType]; !ok {
+		mediaTypes = append(mediaTypes, mediaType)
+	}
+	codecs[mediaType] = c
+}
+
+type codecKey struct{}
+
+type negotiated struct {
+	mediaType string
+	codec     Codec
+}
+
+// Negotiate picks the response codec from the Accept header before the
+// handler runs, so unsupported types get 406 without side effects.
+func Negotiate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, ok := negotiate(r.Header.Get("Accept"))
+		if !ok {
+			writeJSON(w, http.StatusNotAcceptable, errorBody{Error: "none of the accepted media types is supported, use one of: " + strings.Join(mediaTypes, ", ")})
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), codecKey{}, n)))
+	})
+}
+
+func negotiate(accept string) (negotiated, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return negotiated{"application/json", codecs["application/json"]}, true
+	}
+
+	type candidate struct {
+		mediaType string
+		q         float64
+	}
+	var candidates []candidate
+	var excluded []string
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{mediaType, q})
+		} else {
+			excluded = append(excluded, mediaType)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+
+	// A q=0 entry refuses the types it matches unless a more specific
+	// entry accepts them, so "application/json;q=0, */*" rules out JSON.
+	refused := func(pattern, mediaType string) bool {
+		for _, e := range excluded {
+			if matchMediaType(e, mediaType) && specificity(e) > specificity(pattern) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, c := range candidates {
+		for _, mt := range mediaTypes {
+			if matchMediaType(c.mediaType, mt) && !refused(c.mediaType, mt) {
+				return negotiated{mt, codecs[mt]}, true
+			}
+		}
+	}
+	return negotiated{}, false
+}
+
+// specificity ranks */* below type/* below a full media type.
+func specificity(pattern string) int {
+	switch {
+	case pattern == "*/*":
+		return 0
+	case strings.HasSuffix(pattern, "/*"):
+		return 1
+	}
+	return 2
+}
+
+func matchMediaType(pattern, mediaType string) bool {
+	if pattern == "*/*" || pattern == mediaType {
+		return true
+	}
+	prefix, ok := strings.CutSuffix(pattern, "/*")
+	return ok && strings.HasPrefix(mediaType, prefix+"/")
+}
+
+// respond encodes data with the codec chosen by Negotiate, falling back to
+// negotiating from the request for handlers mounted without it.
+func respond(w http.ResponseWriter, r *http.Request, status int, data any) {
+	n, ok := r.Context().Value(codecKey{}).(negotiated)
+	if !ok {
+		if n, ok = negotiate(r.Header.Get("Accept")); !ok {
+			n = negotiated{"application/json", codecs["application/json"]}
+		}
+	}
+	w.Header().Set("Content-Type", n.mediaType)
+	w.WriteHeader(status)
+	n.codec.Encode(w, data)
+}
+
+// requestCodec returns the codec for the request's Content-Type.
+func requestCodec(r *http.Request) (Codec, error) {
+	ct := r.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(ct)
+	if err == nil {
+		if c, ok := codecs[mediaType]; ok {
+			return c, nil
+		}
+	}
+	return nil, &decodeError{http.StatusUnsupportedMediaType, fmt.Sprintf("Content-Type must be one of %s, got %q", strings.Join(mediaTypes, ", "), ct)}
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Encode(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+func (jsonCodec) Decode(r io.Reader, v any) error {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	end := dec.InputOffset()
+	if _, err := dec.Token(); err != io.EOF {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return err
+		}
+		return &decodeError{http.StatusBadRequest, fmt.Sprintf("body must contain a single JSON value, found more data after offset %d", end)}
+	}
+	return nil
+}
+
+// xmlCodec encodes slices as the children of a <list> root element, since
+// XML documents need a single root.
+type xmlCodec struct{}
+
+func (xmlCodec) Encode(w io.Writer, v any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		return enc.Encode(v)
+	}
+	list := xml.StartElement{Name: xml.Name{Local: "list"}}
+	if err := enc.EncodeToken(list); err != nil {
+		return err
+	}
+	for i := 0; i < rv.Len(); i++ {
+		if err := enc.Encode(rv.Index(i).Interface()); err != nil {
+			return err
+		}
+	}
+	if err := enc.EncodeToken(list.End()); err != nil {
+		return err
+	}
+	return enc.Flush()
+}
+
+// Decode rejects elements that no field of v decodes, which encoding/xml
+// would otherwise skip, so misspelt fields fail as they do in JSON.
+func (xmlCodec) Decode(r io.Reader, v any) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		switch tok := tok.(type) {
+		case xml.CharData:
+			if len(strings.TrimSpace(string(tok))) > 0 {
+				return &decodeError{http.StatusBadRequest, fmt.Sprintf("body must contain a single XML element, found more data after offset %d", dec.InputOffset())}
+			}
+		case xml.Comment, xml.ProcInst:
+		default:
+			return &decodeError{http.StatusBadRequest, fmt.Sprintf("body must contain a single XML element, found more data after offset %d", dec.InputOffset())}
+		}
+	}
+	known := xmlElementsOf(reflect.TypeOf(v), nil)
+	if known.any {
+		return nil
+	}
+	return checkXMLElements(xml.NewDecoder(bytes.NewReader(data)), known, "")
+}
+
+// xmlElements are the child elements a type decodes. A nil value, or any,
+// accepts whatever it holds.
+type xmlElements struct {
+	any      bool
+	children map[string]*xmlElements
+}
+
+func xmlElementsOf(t reflect.Type, seen map[reflect.Type]bool) *xmlElements {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || seen[t] ||
+		reflect.PointerTo(t).Implements(reflect.TypeFor[xml.Unmarshaler]()) ||
+		reflect.PointerTo(t).Implements(reflect.TypeFor[encoding.TextUnmarshaler]()) {
+		return &xmlElements{any: true}
+	}
+	seen = maps.Clone(seen)
+	if seen == nil {
+		seen = map[reflect.Type]bool{}
+	}
+	seen[t] = true
+
+	e := &xmlElements{children: map[string]*xmlElements{}}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("xml"), ",")
+		if name == "-" || f.Name == "XMLName" {
+			continue
+		}
+		hasOpt := func(opt string) bool { return slices.Contains(strings.Split(opts, ","), opt) }
+		switch {
+		case hasOpt("any"), hasOpt("innerxml"):
+			return &xmlElements{any: true}
+		case hasOpt("attr"), hasOpt("chardata"), hasOpt("cdata"), hasOpt("comment"):
+			continue
+		case f.Anonymous && name == "":
+			embedded := xmlElementsOf(f.Type, seen)
+			if embedded.any {
+				return embedded
+			}
+			maps.Copy(e.children, embedded.children)
+			continue
+		case !f.IsExported():
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		parent := e
+		path := strings.Split(name, ">")
+		for _, elem := range path[:len(path)-1] {
+			if parent.children[elem] == nil {
+				parent.children[elem] = &xmlElements{children: map[string]*xmlElements{}}
+			}
+			parent = parent.children[elem]
+		}
+		parent.children[path[len(path)-1]] = xmlElementsOf(f.Type, seen)
+	}
+	return e
+}
+
+// checkXMLElements walks the element dec is in, or the document when path
+// is empty, and reports the first child that known does not list.
+func checkXMLElements(dec *xml.Decoder, known *xmlElements, path string) error {
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF && path == "" {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			if path == "" {
+				// The root element is the value itself.
+				if err := checkXMLElements(dec, known, "/"+tok.Name.Local); err != nil {
+					return err
+				}
+				continue
+			}
+			child, ok := known.children[tok.Name.Local]
+			if !ok {
+				return &decodeError{http.StatusBadRequest, fmt.Sprintf("body contains unknown element %s/%s", path, tok.Name.Local)}
+			}
+			if child.any {
+				err = dec.Skip()
+			} else {
+				err = checkXMLElements(dec, child, path+"/"+tok.Name.Local)
+			}
+			if err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// msgpackCodec uses the json struct tags so field names match across formats.
+type msgpackCodec struct{}
+
+func (msgpackCodec) Encode(w io.Writer, v any) error {
+	enc := msgpack.NewEncoder(w)
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+	return enc.Encode(v)
+}
+
+func (msgpackCodec) Decode(r io.Reader, v any) error {
+	dec := msgpack.NewDecoder(r)
+	dec.SetCustomStructTag("json")
+	dec.DisallowUnknownFields(true)
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if err := dec.Skip(); err != io.EOF {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return err
+		}
+		return &decodeError{http.StatusBadRequest, "body must contain a single MessagePack value"}
+	}
+	return nil
+}
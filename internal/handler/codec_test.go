@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"practice4/practice-4/pkg/modules"
+)
+
+func TestXMLDecodeRejectsUnknownElements(t *testing.T) {
+	for _, tc := range []struct {
+		name, body string
+		dst        any
+		err        string
+	}{
+		{"known fields", `<user><name>Alice</name><email>a@example.com</email></user>`, &modules.UserInput{}, ""},
+		{"whitespace and comments", "<user>\n  <!-- hi -->\n  <name>Alice</name>\n</user>\n", &modules.UserInput{}, ""},
+		{"unknown field", `<user><name>Alice</name><emial>a@example.com</emial></user>`, &modules.UserInput{}, "unknown element /user/emial"},
+		{"nested path", `<webhook><url>https://example.com</url><event_types><event_type>UserCreated</event_type></event_types></webhook>`, &modules.WebhookInput{}, ""},
+		{"unknown in nested path", `<webhook><event_types><type>UserCreated</type></event_types></webhook>`, &modules.WebhookInput{}, "unknown element /webhook/event_types/type"},
+		{"attributes are ignored", `<user id="1"><name>Alice</name></user>`, &modules.UserInput{}, ""},
+		{"any catch-all", `<doc><a>1</a><b/></doc>`, &struct {
+			XMLName xml.Name `xml:"doc"`
+			A       string   `xml:"a"`
+			Rest    []any    `xml:",any"`
+		}{}, ""},
+		{"trailing element", `<user><name>Alice</name></user><user/>`, &modules.UserInput{}, "single XML element"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := xmlCodec{}.Decode(strings.NewReader(tc.body), tc.dst)
+			if tc.err == "" {
+				if err != nil {
+					t.Fatalf("Decode = %v, want no error", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("Decode = %v, want an error mentioning %q", err, tc.err)
+			}
+		})
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	for _, tc := range []struct {
+		accept, want string
+	}{
+		{"", "application/json"},
+		{"application/xml", "application/xml"},
+		{"application/msgpack, application/json", "application/msgpack"},
+		{"text/html, application/xml;q=0.5, application/json;q=0.9", "application/json"},
+		{"*/*", "application/json"},
+		{"application/*", "application/json"},
+		{"text/html, */*;q=0.1", "application/json"},
+		{"application/json;q=0, */*", "application/xml"},
+		{"application/json;q=0, application/xml;q=0, application/*", "application/msgpack"},
+		{"application/*;q=0, application/xml", "application/xml"},
+		{"*/*;q=0, application/msgpack", "application/msgpack"},
+		{"bogus, application/xml", "application/xml"},
+		{"text/html", ""},
+		{"application/json;q=0", ""},
+		{"application/*;q=0, */*", ""},
+	} {
+		t.Run(tc.accept, func(t *testing.T) {
+			n, ok := negotiate(tc.accept)
+			if tc.want == "" {
+				if ok {
+					t.Fatalf("negotiate = %s, want no match", n.mediaType)
+				}
+				return
+			}
+			if !ok || n.mediaType != tc.want {
+				t.Fatalf("negotiate = %q, %t; want %q", n.mediaType, ok, tc.want)
+			}
+		})
+	}
+}
+
+func TestNegotiateNotAcceptable(t *testing.T) {
+	called := false
+	h := Negotiate(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { called = true }))
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.Header.Set("Accept", "text/html")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if called {
+		t.Error("handler ran for an unacceptable request")
+	}
+	if rec.Code != http.StatusNotAcceptable {
+		t.Errorf("status = %d, want 406", rec.Code)
+	}
+	var body errorBody
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if want := "use one of: application/json, application/xml, application/msgpack"; !strings.Contains(body.Error, want) {
+		t.Errorf("error = %q, want it to contain %q", body.Error, want)
+	}
+}
+
+func TestXMLEncodesSlicesUnderList(t *testing.T) {
+	var buf bytes.Buffer
+	users := []modules.User{{ID: 1, Name: "Alice"}, {ID: 2, Name: "Bob"}}
+	if err := (xmlCodec{}).Encode(&buf, users); err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		XMLName xml.Name       `xml:"list"`
+		Users   []modules.User `xml:"user"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("Unmarshal(%s): %v", buf.String(), err)
+	}
+	if len(got.Users) != 2 || got.Users[0].Name != "Alice" || got.Users[1].Name != "Bob" {
+		t.Errorf("decoded %+v from %s", got.Users, buf.String())
+	}
+	if !strings.HasPrefix(buf.String(), xml.Header+"<list>") {
+		t.Errorf("body %q does not start with the header and <list>", buf.String())
+	}
+}
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	want := modules.UserInput{Name: "Alice", Email: "alice@example.com"}
+	var buf bytes.Buffer
+	if err := (msgpackCodec{}).Encode(&buf, want); err != nil {
+		t.Fatal(err)
+	}
+
+	users := &stubUsers{}
+	req := httptest.NewRequest(http.MethodPost, "/users", &buf)
+	req.Header.Set("Content-Type", "application/msgpack")
+	req.Header.Set("Accept", "application/msgpack")
+	rec := httptest.NewRecorder()
+	Negotiate(http.HandlerFunc(NewUserHandler(users).Create)).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201; body %q", rec.Code, rec.Body)
+	}
+	if users.created == nil || users.created.Name != want.Name || users.created.Email != want.Email {
+		t.Errorf("created %+v, want %+v", users.created, want)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/msgpack" {
+		t.Errorf("Content-Type = %q, want application/msgpack", ct)
+	}
+	var body createdBody
+	if err := (msgpackCodec{}).Decode(rec.Body, &body); err != nil || body.ID != 1 {
+		t.Errorf("decoded %+v, %v; want id 1", body, err)
+	}
+}
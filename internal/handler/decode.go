@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const maxBodyBytes = 1 << 20
+
+type decodeError struct {
+	status int
+	msg    string
+}
+
+func (e *decodeError) Error() string { return e.msg }
+
+// decodeBody decodes a single value from the request body into dst with the
+// codec for its Content-Type, rejecting unsupported types, oversized bodies,
+// unknown fields and trailing data.
+func decodeBody(w http.ResponseWriter, r *http.Request, dst any) error {
+	codec, err := requestCodec(r)
+	if err != nil {
+		return err
+	}
+	if err := codec.Decode(http.MaxBytesReader(w, r.Body, maxBodyBytes), dst); err != nil {
+		return describeDecodeError(err)
+	}
+	return nil
+}
+
+func describeDecodeError(err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var maxErr *http.MaxBytesError
+	var de *decodeError
+	switch {
+	case errors.As(err, &de):
+		return de
+	case errors.As(err, &maxErr):
+		return &decodeError{http.StatusRequestEntityTooLarge, fmt.Sprintf("body must not be larger than %d bytes", maxErr.Limit)}
+	case errors.As(err, &syntaxErr):
+		return &decodeError{http.StatusBadRequest, fmt.Sprintf("malformed JSON at offset %d: %v", syntaxErr.Offset, err)}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return &decodeError{http.StatusBadRequest, "malformed JSON: unexpected end of body"}
+	case errors.As(err, &typeErr):
+		if typeErr.Field != "" {
+			return &decodeError{http.StatusBadRequest, fmt.Sprintf("field %q must be %s, got %s at offset %d", typeErr.Field, typeErr.Type, typeErr.Value, typeErr.Offset)}
+		}
+		return &decodeError{http.StatusBadRequest, fmt.Sprintf("body must be a JSON object, got %s", typeErr.Value)}
+	case errors.Is(err, io.EOF):
+		return &decodeError{http.StatusBadRequest, "body must not be empty"}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		return &decodeError{http.StatusBadRequest, "unknown field " + strings.TrimPrefix(err.Error(), "json: unknown field ")}
+	default:
+		return &decodeError{http.StatusBadRequest, err.Error()}
+	}
+}
+
+func writeDecodeError(w http.ResponseWriter, r *http.Request, err error) {
+	var de *decodeError
+	if errors.As(err, &de) {
+		respond(w, r, de.status, errorBody{Error: de.msg})
+		return
+	}
+	respond(w, r, http.StatusBadRequest, errorBody{Error: err.Error()})
+}
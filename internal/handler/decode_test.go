@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"practice4/practice-4/internal/usecase"
+	"practice4/practice-4/pkg/modules"
+)
+
+// stubUsers accepts every create and records the last user it was given.
+type stubUsers struct {
+	usecase.UserUsecase
+	created *modules.User
+}
+
+func (s *stubUsers) Create(_ context.Context, u *modules.User) (int64, error) {
+	s.created = u
+	return 1, nil
+}
+
+func TestDecodeBody(t *testing.T) {
+	for _, tc := range []struct {
+		name, contentType, body string
+		status                  int
+		err                     string
+	}{
+		{"valid", "application/json", `{"name":"Alice","email":"a@example.com"}`, http.StatusCreated, ""},
+		{"content type parameters", "application/json; charset=utf-8", `{"name":"Alice","email":"a@example.com"}`, http.StatusCreated, ""},
+		{"wrong content type", "text/plain", `{"name":"Alice"}`, http.StatusUnsupportedMediaType,
+			`Content-Type must be one of application/json, application/xml, application/msgpack, got "text/plain"`},
+		{"missing content type", "", `{"name":"Alice"}`, http.StatusUnsupportedMediaType, `got ""`},
+		{"too large", "application/json", `{"name":"` + strings.Repeat("a", maxBodyBytes) + `"}`, http.StatusRequestEntityTooLarge,
+			"body must not be larger than 1048576 bytes"},
+		{"unknown field", "application/json", `{"name":"Alice","emial":"a@example.com"}`, http.StatusBadRequest, `unknown field "emial"`},
+		{"trailing data", "application/json", `{"name":"Alice"} {"name":"Bob"}`, http.StatusBadRequest,
+			"body must contain a single JSON value, found more data after offset 16"},
+		{"trailing garbage", "application/json", `{"name":"Alice"}x`, http.StatusBadRequest, "found more data after offset 16"},
+		{"syntax error", "application/json", `{"name":"Alice",}`, http.StatusBadRequest,
+			"malformed JSON at offset 17: invalid character '}' looking for beginning of object key string"},
+		{"truncated", "application/json", `{"name":"Al`, http.StatusBadRequest, "malformed JSON: unexpected end of body"},
+		{"wrong field type", "application/json", `{"name":1}`, http.StatusBadRequest, `field "name" must be string, got number at offset 9`},
+		{"not an object", "application/json", `[1]`, http.StatusBadRequest, "body must be a JSON object, got array"},
+		{"empty", "application/json", ``, http.StatusBadRequest, "body must not be empty"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			users := &stubUsers{}
+			req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(tc.body))
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			rec := httptest.NewRecorder()
+			NewUserHandler(users).Create(rec, req)
+
+			if rec.Code != tc.status {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, tc.status, rec.Body)
+			}
+			if tc.err == "" {
+				if users.created == nil || users.created.Name != "Alice" {
+					t.Errorf("created %+v, want Alice", users.created)
+				}
+				return
+			}
+			if users.created != nil {
+				t.Errorf("created %+v from a rejected body", users.created)
+			}
+			var body errorBody
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(body.Error, tc.err) {
+				t.Errorf("error = %q, want it to contain %q", body.Error, tc.err)
+			}
+		})
+	}
+}
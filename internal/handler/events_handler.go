@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"practice4/practice-4/internal/events"
+	"practice4/practice-4/internal/usecase"
+	"practice4/practice-4/pkg/modules"
+	"strconv"
+	"time"
+)
+
+const replayPageSize = 500
+
+// UserEventsHandler streams user changes as Server-Sent Events. Each event's
+// ID is the audit log entry's commit sequence number, so a reconnecting
+// client that sends Last-Event-ID first receives the entries it missed,
+// including ones with a lower entry ID that committed later.
+type UserEventsHandler struct {
+	uc        usecase.UserUsecase
+	broker    *events.Broker
+	heartbeat time.Duration
+}
+
+func NewUserEventsHandler(uc usecase.UserUsecase, broker *events.Broker, heartbeat time.Duration) *UserEventsHandler {
+	return &UserEventsHandler{uc: uc, broker: broker, heartbeat: heartbeat}
+}
+
+func (h *UserEventsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var lastSeq int64
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		seq, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || seq < 0 {
+			writeJSON(w, http.StatusBadRequest, errorBody{Error: "invalid Last-Event-ID"})
+			return
+		}
+		lastSeq = seq
+	}
+
+	// Subscribe before replaying so that nothing committed in between is lost.
+	// Changes arrive in commit order, so duplicates are skipped by seq below.
+	changes, unsubscribe := h.broker.Subscribe()
+	defer unsubscribe()
+
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && err != http.ErrNotSupported {
+		writeJSON(w, http.StatusInternalServerError, errorBody{Error: "Internal server error"})
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(entry modules.AuditLog) error {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", entry.Seq, entry.Action, data); err != nil {
+			return err
+		}
+		lastSeq = entry.Seq
+		return rc.Flush()
+	}
+
+	if lastSeq > 0 {
+		for {
+			logs, err := h.uc.ListAuditLogs(r.Context(), modules.AuditLogFilter{AfterSeq: lastSeq, Limit: replayPageSize})
+			if err != nil {
+				return
+			}
+			for _, entry := range logs {
+				if send(entry) != nil {
+					return
+				}
+			}
+			if len(logs) < replayPageSize {
+				break
+			}
+		}
+	}
+	if rc.Flush() != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case entry, ok := <-changes:
+			if !ok {
+				return
+			}
+			if entry.Seq > lastSeq && send(entry) != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil || rc.Flush() != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"practice4/practice-4/internal/events"
+	"practice4/practice-4/internal/usecase"
+	"practice4/practice-4/pkg/modules"
+)
+
+// auditUsers serves a fixed audit log, by commit sequence.
+type auditUsers struct {
+	usecase.UserUsecase
+	logs []modules.AuditLog
+}
+
+func (u auditUsers) ListAuditLogs(_ context.Context, f modules.AuditLogFilter) ([]modules.AuditLog, error) {
+	var out []modules.AuditLog
+	for _, e := range u.logs {
+		if e.Seq > f.AfterSeq {
+			out = append(out, e)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Seq < out[j].Seq })
+	return out[:min(len(out), int(f.Limit))], nil
+}
+
+// chanFeed hands subscribers whatever is sent on its channel.
+type chanFeed chan modules.AuditLog
+
+func (f chanFeed) ListenChanges(ctx context.Context, handle func(modules.AuditLog)) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case e := <-f:
+			handle(e)
+		}
+	}
+}
+
+type sseEvent struct {
+	id, event string
+	data      modules.AuditLog
+	comment   string
+}
+
+// stream opens the event stream and returns a function reading its next
+// event, which reports ok false at the end of the stream.
+func stream(t *testing.T, h http.Handler, lastEventID string) func() (sseEvent, bool) {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("status %d with Content-Type %q, want a 200 event stream", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	lines, done := make(chan string), make(chan struct{})
+	t.Cleanup(func() { close(done) })
+	go func() {
+		defer close(lines)
+		sc := bufio.NewScanner(resp.Body)
+		for sc.Scan() {
+			select {
+			case lines <- sc.Text():
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() (sseEvent, bool) {
+		t.Helper()
+		var ev sseEvent
+		for {
+			select {
+			case line, ok := <-lines:
+				if !ok {
+					return ev, false
+				}
+				if line == "" {
+					return ev, true
+				}
+				field, value, _ := strings.Cut(line, ": ")
+				switch field {
+				case "":
+					ev.comment = value
+				case "id":
+					ev.id = value
+				case "event":
+					ev.event = value
+				case "data":
+					if err := json.Unmarshal([]byte(value), &ev.data); err != nil {
+						t.Fatalf("data %q: %v", value, err)
+					}
+				}
+			case <-time.After(time.Second):
+				t.Fatal("no event within a second")
+			}
+		}
+	}
+}
+
+func startBroker(t *testing.T) (*events.Broker, chanFeed) {
+	t.Helper()
+	feed := make(chanFeed)
+	b := events.NewBroker(feed)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go b.Run(ctx)
+	return b, feed
+}
+
+func TestUserEventsResumeInCommitOrder(t *testing.T) {
+	// Entry 3 was inserted after entry 2 but committed first.
+	logs := []modules.AuditLog{
+		{ID: 1, UserID: 1, Action: "UserCreated", Seq: 1},
+		{ID: 3, UserID: 3, Action: "UserCreated", Seq: 2},
+		{ID: 2, UserID: 2, Action: "UserCreated", Seq: 3},
+		{ID: 4, UserID: 1, Action: "UserUpdated", Seq: 4},
+	}
+	broker, feed := startBroker(t)
+	next := stream(t, NewUserEventsHandler(auditUsers{logs: logs}, broker, time.Hour), "1")
+
+	for _, want := range logs[1:] {
+		ev, ok := next()
+		if !ok {
+			t.Fatal("stream ended during replay")
+		}
+		if ev.id != fmt.Sprint(want.Seq) || ev.event != want.Action || ev.data.ID != want.ID {
+			t.Fatalf("event id %s %s for entry %d, want id %d %s for entry %d", ev.id, ev.event, ev.data.ID, want.Seq, want.Action, want.ID)
+		}
+	}
+
+	// A live change already replayed is skipped; the next one is sent.
+	feed <- logs[3]
+	feed <- modules.AuditLog{ID: 5, UserID: 2, Action: "UserDeleted", Seq: 5}
+	if ev, ok := next(); !ok || ev.id != "5" || ev.data.ID != 5 {
+		t.Fatalf("live event %+v (open %t), want id 5 for entry 5", ev, ok)
+	}
+
+	broker.Close()
+	if ev, ok := next(); ok {
+		t.Fatalf("received %+v after the broker closed, want the stream to end", ev)
+	}
+}
+
+func TestUserEventsWithoutLastEventIDSkipReplay(t *testing.T) {
+	logs := []modules.AuditLog{{ID: 1, Action: "UserCreated", Seq: 1}}
+	broker, feed := startBroker(t)
+	next := stream(t, NewUserEventsHandler(auditUsers{logs: logs}, broker, time.Hour), "")
+
+	feed <- modules.AuditLog{ID: 2, Action: "UserCreated", Seq: 2}
+	if ev, ok := next(); !ok || ev.id != "2" {
+		t.Fatalf("first event %+v (open %t), want the live entry 2", ev, ok)
+	}
+}
+
+func TestUserEventsHeartbeat(t *testing.T) {
+	broker, _ := startBroker(t)
+	next := stream(t, NewUserEventsHandler(auditUsers{}, broker, 10*time.Millisecond), "")
+	for i := 0; i < 2; i++ {
+		if ev, ok := next(); !ok || ev.comment != "heartbeat" || ev.id != "" {
+			t.Fatalf("event %+v (open %t), want a heartbeat comment", ev, ok)
+		}
+	}
+}
+
+func TestUserEventsRejectsBadLastEventID(t *testing.T) {
+	broker, _ := startBroker(t)
+	for _, id := range []string{"abc", "-1"} {
+		req := httptest.NewRequest(http.MethodGet, "/users/events", nil)
+		req.Header.Set("Last-Event-ID", id)
+		rec := httptest.NewRecorder()
+		NewUserEventsHandler(auditUsers{}, broker, time.Hour).ServeHTTP(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("Last-Event-ID %q: status = %d, want 400", id, rec.Code)
+		}
+	}
+}
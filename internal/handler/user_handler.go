@@ -2,6 +2,7 @@ package handler
 
 import (
 	"encoding/json"
+	"encoding/xml"
 	"errors"
 	"net/http"
 	"practice4/practice-4/internal/usecase"
@@ -24,27 +25,40 @@ func writeJSON(w http.ResponseWriter, status int, data any) {
 	json.NewEncoder(w).Encode(data)
 }
 
-func errorResponse(w http.ResponseWriter, err error) {
+// Response bodies name their XML root element, which maps cannot express.
+type errorBody struct {
+	XMLName xml.Name `json:"-" xml:"response"`
+	Error   string   `json:"error" xml:"error"`
+}
+
+type messageBody struct {
+	XMLName xml.Name `json:"-" xml:"response"`
+	Message string   `json:"message" xml:"message"`
+}
+
+type createdBody struct {
+	XMLName xml.Name `json:"-" xml:"response"`
+	ID      int64    `json:"id" xml:"id"`
+}
+
+func errorResponse(w http.ResponseWriter, r *http.Request, err error) {
 	switch {
 	case errors.Is(err, apperrors.ErrNotFound):
-		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		respond(w, r, http.StatusNotFound, errorBody{Error: err.Error()})
 	case errors.Is(err, apperrors.ErrValidation):
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		respond(w, r, http.StatusBadRequest, errorBody{Error: err.Error()})
+	case errors.Is(err, apperrors.ErrConflict):
+		respond(w, r, http.StatusConflict, errorBody{Error: err.Error()})
+	case errors.Is(err, apperrors.ErrTimeout):
+		respond(w, r, http.StatusGatewayTimeout, errorBody{Error: "Request timed out"})
+	case errors.Is(err, apperrors.ErrTransient):
+		w.Header().Set("Retry-After", "1")
+		respond(w, r, http.StatusServiceUnavailable, errorBody{Error: "Conflicting concurrent change, retry the request"})
 	default:
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		respond(w, r, http.StatusInternalServerError, errorBody{Error: "Internal server error"})
 	}
 }
 
-// GetAll godoc
-// @Summary Get all users
-// @Tags users
-// @Produce json
-// @Param limit query int false "Limit" default(10)
-// @Param offset query int false "Offset" default(0)
-// @Success 200 {object} modules.PaginatedUsers
-// @Failure 500 {object} map[string]string
-// @Security ApiKeyAuth
-// @Router /users [get]
 func (h *UserHandler) GetAll(w http.ResponseWriter, r *http.Request) {
 	limit, err := strconv.ParseInt(r.URL.Query().Get("limit"), 10, 64)
 	if err != nil || limit <= 0 {
@@ -58,139 +72,157 @@ func (h *UserHandler) GetAll(w http.ResponseWriter, r *http.Request) {
 		offset = 0
 	}
 
-	result, err := h.uc.GetAll(r.Context(), limit, offset)
+	filter := modules.UserFilter{Name: r.URL.Query().Get("name"), Email: r.URL.Query().Get("email")}
+	if v := r.URL.Query().Get("after_id"); v != "" {
+		if filter.AfterID, err = strconv.ParseInt(v, 10, 64); err != nil || filter.AfterID < 0 {
+			respond(w, r, http.StatusBadRequest, errorBody{Error: "invalid after_id"})
+			return
+		}
+	}
+	result, err := h.uc.GetAll(r.Context(), filter, limit, offset)
 	if err != nil {
-		errorResponse(w, err)
+		errorResponse(w, r, err)
 		return
 	}
-	writeJSON(w, http.StatusOK, result)
+	respond(w, r, http.StatusOK, result)
+}
+
+func (h *UserHandler) Search(w http.ResponseWriter, r *http.Request) {
+	limit, err := strconv.ParseInt(r.URL.Query().Get("limit"), 10, 64)
+	if err != nil || limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	results, err := h.uc.Search(r.Context(), r.URL.Query().Get("q"), limit)
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+	respond(w, r, http.StatusOK, results)
 }
 
-// GetByID godoc
-// @Summary Get user by ID
-// @Tags users
-// @Produce json
-// @Param id path int true "User ID"
-// @Success 200 {object} modules.User
-// @Failure 404 {object} map[string]string
-// @Security ApiKeyAuth
-// @Router /users/{id} [get]
 func (h *UserHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid user ID"})
+		respond(w, r, http.StatusBadRequest, errorBody{Error: "invalid user ID"})
 		return
 	}
 
 	user, err := h.uc.GetByID(r.Context(), id)
 	if err != nil {
-		errorResponse(w, err)
+		errorResponse(w, r, err)
 		return
 	}
-	writeJSON(w, http.StatusOK, user)
+	respond(w, r, http.StatusOK, user)
 }
 
-// Create godoc
-// @Summary Create user
-// @Tags users
-// @Accept json
-// @Produce json
-// @Param user body modules.UserInput true "User"
-// @Success 201 {object} map[string]int64
-// @Failure 400 {object} map[string]string
-// @Security ApiKeyAuth
-// @Router /users [post]
 func (h *UserHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var input modules.UserInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+	if err := decodeBody(w, r, &input); err != nil {
+		writeDecodeError(w, r, err)
 		return
 	}
 
 	id, err := h.uc.Create(r.Context(), &modules.User{Name: input.Name, Email: input.Email})
 	if err != nil {
-		errorResponse(w, err)
+		errorResponse(w, r, err)
 		return
 	}
-	writeJSON(w, http.StatusCreated, map[string]int64{"id": id})
+	respond(w, r, http.StatusCreated, createdBody{ID: id})
 }
 
-// Update godoc
-// @Summary Update user
-// @Tags users
-// @Accept json
-// @Produce json
-// @Param id path int true "User ID"
-// @Param user body modules.UserInput true "User"
-// @Success 200 {object} map[string]string
-// @Failure 400 {object} map[string]string
-// @Failure 404 {object} map[string]string
-// @Security ApiKeyAuth
-// @Router /users/{id} [put]
 func (h *UserHandler) Update(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid user ID"})
+		respond(w, r, http.StatusBadRequest, errorBody{Error: "invalid user ID"})
 		return
 	}
 
 	var input modules.UserInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+	if err := decodeBody(w, r, &input); err != nil {
+		writeDecodeError(w, r, err)
 		return
 	}
 
 	if err := h.uc.Update(r.Context(), &modules.User{ID: id, Name: input.Name, Email: input.Email}); err != nil {
-		errorResponse(w, err)
+		errorResponse(w, r, err)
 		return
 	}
-	writeJSON(w, http.StatusOK, map[string]string{"message": "user updated"})
+	respond(w, r, http.StatusOK, messageBody{Message: "user updated"})
 }
 
-// Delete godoc
-// @Summary Soft delete user
-// @Tags users
-// @Produce json
-// @Param id path int true "User ID"
-// @Success 204
-// @Failure 404 {object} map[string]string
-// @Security ApiKeyAuth
-// @Router /users/{id} [delete]
 func (h *UserHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid user ID"})
+		respond(w, r, http.StatusBadRequest, errorBody{Error: "invalid user ID"})
 		return
 	}
 
 	if err := h.uc.Delete(r.Context(), id); err != nil {
-		errorResponse(w, err)
+		errorResponse(w, r, err)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// CreateWithAudit godoc
-// @Summary Create user with audit log
-// @Tags users
-// @Accept json
-// @Produce json
-// @Param user body modules.UserInput true "User"
-// @Success 201 {object} map[string]int64
-// @Failure 400 {object} map[string]string
-// @Security ApiKeyAuth
-// @Router /users/audit [post]
 func (h *UserHandler) CreateWithAudit(w http.ResponseWriter, r *http.Request) {
 	var input modules.UserInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+	if err := decodeBody(w, r, &input); err != nil {
+		writeDecodeError(w, r, err)
 		return
 	}
 
 	id, err := h.uc.CreateUserWithAudit(r.Context(), &modules.User{Name: input.Name, Email: input.Email})
 	if err != nil {
-		errorResponse(w, err)
+		errorResponse(w, r, err)
+		return
+	}
+	respond(w, r, http.StatusCreated, createdBody{ID: id})
+}
+
+func (h *UserHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		respond(w, r, http.StatusBadRequest, errorBody{Error: "invalid user ID"})
+		return
+	}
+
+	if err := h.uc.Restore(r.Context(), id); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+	respond(w, r, http.StatusOK, messageBody{Message: "user restored"})
+}
+
+func (h *UserHandler) ListAuditLogs(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var filter modules.AuditLogFilter
+	var err error
+	for _, p := range []struct {
+		name string
+		dst  *int64
+	}{{"user_id", &filter.UserID}, {"after_id", &filter.AfterID}, {"after_seq", &filter.AfterSeq}, {"limit", &filter.Limit}} {
+		if v := q.Get(p.name); v != "" {
+			if *p.dst, err = strconv.ParseInt(v, 10, 64); err != nil {
+				respond(w, r, http.StatusBadRequest, errorBody{Error: "invalid " + p.name})
+				return
+			}
+		}
+	}
+	if v := q.Get("tail"); v != "" {
+		if filter.Tail, err = strconv.ParseBool(v); err != nil {
+			respond(w, r, http.StatusBadRequest, errorBody{Error: "invalid tail"})
+			return
+		}
+	}
+
+	logs, err := h.uc.ListAuditLogs(r.Context(), filter)
+	if err != nil {
+		errorResponse(w, r, err)
 		return
 	}
-	writeJSON(w, http.StatusCreated, map[string]int64{"id": id})
+	respond(w, r, http.StatusOK, logs)
 }
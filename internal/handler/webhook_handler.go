@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"net/http"
+	"practice4/practice-4/internal/usecase"
+	"practice4/practice-4/pkg/modules"
+	"strconv"
+)
+
+type WebhookHandler struct {
+	uc usecase.WebhookUsecase
+}
+
+func NewWebhookHandler(uc usecase.WebhookUsecase) *WebhookHandler {
+	return &WebhookHandler{uc: uc}
+}
+
+// pathIDs parses the named path values, answering 400 for the first invalid
+// one.
+func pathIDs(w http.ResponseWriter, r *http.Request, names ...string) ([]int64, bool) {
+	ids := make([]int64, len(names))
+	for i, name := range names {
+		id, err := strconv.ParseInt(r.PathValue(name), 10, 64)
+		if err != nil {
+			respond(w, r, http.StatusBadRequest, errorBody{Error: "invalid " + name})
+			return nil, false
+		}
+		ids[i] = id
+	}
+	return ids, true
+}
+
+func (h *WebhookHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var input modules.WebhookInput
+	if err := decodeBody(w, r, &input); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+
+	sub, err := h.uc.Subscribe(r.Context(), input)
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+	respond(w, r, http.StatusCreated, sub)
+}
+
+func (h *WebhookHandler) List(w http.ResponseWriter, r *http.Request) {
+	subs, err := h.uc.ListSubscriptions(r.Context())
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+	respond(w, r, http.StatusOK, subs)
+}
+
+func (h *WebhookHandler) Get(w http.ResponseWriter, r *http.Request) {
+	ids, ok := pathIDs(w, r, "id")
+	if !ok {
+		return
+	}
+
+	sub, err := h.uc.GetSubscription(r.Context(), ids[0])
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+	respond(w, r, http.StatusOK, sub)
+}
+
+func (h *WebhookHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	ids, ok := pathIDs(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.uc.Unsubscribe(r.Context(), ids[0]); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *WebhookHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	ids, ok := pathIDs(w, r, "id")
+	if !ok {
+		return
+	}
+
+	q := r.URL.Query()
+	filter := modules.DeliveryFilter{Status: q.Get("status")}
+	var err error
+	for _, p := range []struct {
+		name string
+		dst  *int64
+	}{{"before_id", &filter.BeforeID}, {"limit", &filter.Limit}} {
+		if v := q.Get(p.name); v != "" {
+			if *p.dst, err = strconv.ParseInt(v, 10, 64); err != nil {
+				respond(w, r, http.StatusBadRequest, errorBody{Error: "invalid " + p.name})
+				return
+			}
+		}
+	}
+
+	deliveries, err := h.uc.ListDeliveries(r.Context(), ids[0], filter)
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+	respond(w, r, http.StatusOK, deliveries)
+}
+
+func (h *WebhookHandler) GetDelivery(w http.ResponseWriter, r *http.Request) {
+	ids, ok := pathIDs(w, r, "id", "deliveryID")
+	if !ok {
+		return
+	}
+
+	delivery, err := h.uc.GetDelivery(r.Context(), ids[0], ids[1])
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+	respond(w, r, http.StatusOK, delivery)
+}
+
+func (h *WebhookHandler) Replay(w http.ResponseWriter, r *http.Request) {
+	ids, ok := pathIDs(w, r, "id", "deliveryID")
+	if !ok {
+		return
+	}
+
+	delivery, err := h.uc.ReplayDelivery(r.Context(), ids[0], ids[1])
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+	respond(w, r, http.StatusAccepted, delivery)
+}
@@ -0,0 +1,306 @@
+package openapi
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+//go:embed openapi.json
+var specJSON []byte
+
+//go:embed swagger.html
+var swaggerHTML []byte
+
+const specURL = "openapi.json"
+
+// Spec is the parsed OpenAPI document with its schemas compiled for
+// validation. It is the source of truth for the HTTP API.
+type Spec struct {
+	routes []*operation
+}
+
+type operation struct {
+	method      string
+	path        string
+	segments    []string
+	params      []*parameter
+	body        *jsonschema.Schema
+	bodyNeeded  bool
+	bodyTypes   []string
+	responses   map[string]*response
+	defaultResp *response
+	// streaming operations answer with text/event-stream, which cannot be
+	// buffered for response validation.
+	streaming bool
+}
+
+type parameter struct {
+	name     string
+	in       string
+	required bool
+	typ      string
+	schema   *jsonschema.Schema
+}
+
+type response struct {
+	schemas map[string]*jsonschema.Schema
+}
+
+// Load parses and compiles the embedded OpenAPI document.
+func Load() (*Spec, error) {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(specJSON))
+	if err != nil {
+		return nil, fmt.Errorf("openapi: %w", err)
+	}
+	c := jsonschema.NewCompiler()
+	c.DefaultDraft(jsonschema.Draft2020)
+	if err := c.AddResource(specURL, doc); err != nil {
+		return nil, fmt.Errorf("openapi: %w", err)
+	}
+
+	p := &parser{root: doc.(map[string]any), compiler: c}
+	spec, err := p.parse()
+	if err != nil {
+		return nil, fmt.Errorf("openapi: %w", err)
+	}
+	return spec, nil
+}
+
+// JSON returns the raw OpenAPI document.
+func JSON() []byte { return specJSON }
+
+// Handler serves the OpenAPI document.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(specJSON)
+	})
+}
+
+// UIHandler serves a Swagger UI page rendering /openapi.json.
+func UIHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(swaggerHTML)
+	})
+}
+
+// CheckRoutes reports ServeMux patterns ("GET /users/{id}") that the spec
+// does not describe and spec operations that no pattern serves.
+func (s *Spec) CheckRoutes(patterns []string) error {
+	served := make(map[string]bool, len(patterns))
+	for _, p := range patterns {
+		served[p] = true
+	}
+	documented := make(map[string]bool, len(s.routes))
+	for _, op := range s.routes {
+		documented[op.method+" "+op.path] = true
+	}
+
+	var errs []error
+	for _, p := range sortedKeys(served) {
+		if !documented[p] {
+			errs = append(errs, fmt.Errorf("route %q is not described in the OpenAPI spec", p))
+		}
+	}
+	for _, p := range sortedKeys(documented) {
+		if !served[p] {
+			errs = append(errs, fmt.Errorf("spec operation %q has no handler", p))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Spec) find(method, path string) (*operation, map[string]string) {
+	segments := splitPath(path)
+	var best *operation
+	var bestParams map[string]string
+	bestLiterals := -1
+	for _, op := range s.routes {
+		if op.method != method || len(op.segments) != len(segments) {
+			continue
+		}
+		params := map[string]string{}
+		literals := 0
+		ok := true
+		for i, seg := range op.segments {
+			if strings.HasPrefix(seg, "{") {
+				params[strings.Trim(seg, "{}")] = segments[i]
+			} else if seg == segments[i] {
+				literals++
+			} else {
+				ok = false
+				break
+			}
+		}
+		if ok && literals > bestLiterals {
+			best, bestParams, bestLiterals = op, params, literals
+		}
+	}
+	return best, bestParams
+}
+
+type parser struct {
+	root     map[string]any
+	compiler *jsonschema.Compiler
+}
+
+func (p *parser) parse() (*Spec, error) {
+	paths, _ := p.root["paths"].(map[string]any)
+	spec := &Spec{}
+	for _, path := range sortedKeys(paths) {
+		item, _ := paths[path].(map[string]any)
+		for _, method := range sortedKeys(item) {
+			switch method {
+			case "get", "put", "post", "delete", "patch", "head", "options":
+			default:
+				continue
+			}
+			op, err := p.operation(path, method, item[method].(map[string]any))
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", strings.ToUpper(method), path, err)
+			}
+			spec.routes = append(spec.routes, op)
+		}
+	}
+	return spec, nil
+}
+
+func (p *parser) operation(path, method string, raw map[string]any) (*operation, error) {
+	ptr := "#/paths/" + escape(path) + "/" + method
+	op := &operation{
+		method:    strings.ToUpper(method),
+		path:      path,
+		segments:  splitPath(path),
+		responses: map[string]*response{},
+	}
+
+	params, _ := raw["parameters"].([]any)
+	for i, rawParam := range params {
+		paramPtr, obj := p.resolve(fmt.Sprintf("%s/parameters/%d", ptr, i), rawParam)
+		param := &parameter{}
+		param.name, _ = obj["name"].(string)
+		param.in, _ = obj["in"].(string)
+		param.required, _ = obj["required"].(bool)
+		if schema, ok := obj["schema"].(map[string]any); ok {
+			param.typ, _ = schema["type"].(string)
+			compiled, err := p.compiler.Compile(specURL + paramPtr + "/schema")
+			if err != nil {
+				return nil, err
+			}
+			param.schema = compiled
+		}
+		op.params = append(op.params, param)
+	}
+
+	if rawBody, ok := raw["requestBody"]; ok {
+		bodyPtr, obj := p.resolve(ptr+"/requestBody", rawBody)
+		op.bodyNeeded, _ = obj["required"].(bool)
+		content, _ := obj["content"].(map[string]any)
+		for _, mediaType := range sortedKeys(content) {
+			op.bodyTypes = append(op.bodyTypes, mediaType)
+			if mediaType != "application/json" {
+				continue
+			}
+			compiled, err := p.compiler.Compile(specURL + bodyPtr + "/content/" + escape(mediaType) + "/schema")
+			if err != nil {
+				return nil, err
+			}
+			op.body = compiled
+		}
+	}
+
+	responses, _ := raw["responses"].(map[string]any)
+	for code, rawResp := range responses {
+		respPtr, obj := p.resolve(ptr+"/responses/"+code, rawResp)
+		resp := &response{schemas: map[string]*jsonschema.Schema{}}
+		content, _ := obj["content"].(map[string]any)
+		for mediaType, media := range content {
+			if mediaType == "text/event-stream" {
+				op.streaming = true
+			}
+			if m, ok := media.(map[string]any); !ok || m["schema"] == nil {
+				resp.schemas[mediaType] = nil
+				continue
+			}
+			compiled, err := p.compiler.Compile(specURL + respPtr + "/content/" + escape(mediaType) + "/schema")
+			if err != nil {
+				return nil, err
+			}
+			resp.schemas[mediaType] = compiled
+		}
+		if code == "default" {
+			op.defaultResp = resp
+		} else {
+			op.responses[code] = resp
+		}
+	}
+	return op, nil
+}
+
+// resolve follows a local $ref and returns the JSON pointer of the target.
+func (p *parser) resolve(ptr string, v any) (string, map[string]any) {
+	obj, _ := v.(map[string]any)
+	ref, ok := obj["$ref"].(string)
+	if !ok || !strings.HasPrefix(ref, "#/") {
+		return ptr, obj
+	}
+	var cur any = p.root
+	for _, tok := range strings.Split(ref[2:], "/") {
+		tok = strings.NewReplacer("~1", "/", "~0", "~").Replace(tok)
+		m, _ := cur.(map[string]any)
+		cur = m[tok]
+	}
+	return p.resolve(ref, cur)
+}
+
+func escape(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// parseParam converts a raw path or query value to the JSON type its schema
+// declares, so it can be validated like a body value.
+func parseParam(typ, raw string) (any, error) {
+	switch typ {
+	case "integer":
+		if _, err := strconv.ParseInt(raw, 10, 64); err != nil {
+			return nil, errors.New("must be an integer")
+		}
+		return json.Number(raw), nil
+	case "number":
+		if _, err := strconv.ParseFloat(raw, 64); err != nil {
+			return nil, errors.New("must be a number")
+		}
+		return json.Number(raw), nil
+	case "boolean":
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, errors.New("must be a boolean")
+		}
+		return b, nil
+	default:
+		return raw, nil
+	}
+}
@@ -0,0 +1,274 @@
+package openapi_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"practice4/practice-4/internal/events"
+	"practice4/practice-4/internal/gql"
+	"practice4/practice-4/internal/handler"
+	"practice4/practice-4/internal/openapi"
+	"practice4/practice-4/internal/router"
+	"practice4/practice-4/internal/usecase"
+	"practice4/practice-4/pkg/apperrors"
+	"practice4/practice-4/pkg/modules"
+)
+
+const apiKey = "test-key"
+
+func loadSpec(t *testing.T) *openapi.Spec {
+	t.Helper()
+	spec, err := openapi.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return spec
+}
+
+func TestSpecMatchesRoutes(t *testing.T) {
+	if err := loadSpec(t).CheckRoutes(router.Patterns()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// fakeUsers is a UserUsecase holding one user, enough to drive every user
+// handler through a successful response.
+type fakeUsers struct {
+	usecase.UserUsecase
+}
+
+var (
+	created = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	alice   = modules.User{ID: 1, Name: "Alice", Email: "alice@example.com", CreatedAt: created}
+	entry   = modules.AuditLog{ID: 1, UserID: 1, Action: "create", CreatedAt: created, Seq: 1}
+)
+
+func (fakeUsers) GetAll(_ context.Context, _ modules.UserFilter, limit, offset int64) (*modules.PaginatedUsers, error) {
+	return &modules.PaginatedUsers{Users: []modules.User{alice}, Total: 1, Limit: limit, Offset: offset}, nil
+}
+
+func (fakeUsers) GetByID(_ context.Context, id int64) (*modules.User, error) {
+	if id != alice.ID {
+		return nil, apperrors.ErrNotFound
+	}
+	u := alice
+	return &u, nil
+}
+
+func (fakeUsers) Create(context.Context, *modules.User) (int64, error) { return 2, nil }
+
+func (fakeUsers) Update(context.Context, *modules.User) error { return nil }
+
+func (fakeUsers) Delete(context.Context, int64) error { return nil }
+
+func (fakeUsers) CreateUserWithAudit(context.Context, *modules.User) (int64, error) { return 2, nil }
+
+func (fakeUsers) Restore(_ context.Context, id int64) error {
+	if id != alice.ID {
+		return apperrors.ErrNotFound
+	}
+	return nil
+}
+
+func (fakeUsers) ListAuditLogs(context.Context, modules.AuditLogFilter) ([]modules.AuditLog, error) {
+	return []modules.AuditLog{entry}, nil
+}
+
+func (fakeUsers) AuditLogsByUsers(_ context.Context, userIDs []int64, _ int64) (map[int64][]modules.AuditLog, error) {
+	return map[int64][]modules.AuditLog{alice.ID: {entry}}, nil
+}
+
+func (fakeUsers) Search(_ context.Context, query string, _ int64) (*modules.UserSearchResults, error) {
+	return &modules.UserSearchResults{Query: query, Results: []modules.UserSearchResult{
+		{User: alice, Rank: 1, Highlights: modules.UserHighlights{Name: "<mark>Alice</mark>", Email: alice.Email}},
+	}}, nil
+}
+
+// fakeWebhooks is a WebhookUsecase holding one subscription with one dead
+// delivery.
+type fakeWebhooks struct {
+	usecase.WebhookUsecase
+}
+
+var (
+	hook     = modules.WebhookSubscription{ID: 1, URL: "https://example.com/hooks/users", EventTypes: []string{modules.EventUserCreated}, CreatedAt: created}
+	delivery = modules.WebhookDelivery{ID: 1, SubscriptionID: 1, EventID: 1, EventType: modules.EventUserCreated, Status: modules.DeliveryDead,
+		Attempts: 8, NextAttemptAt: created, LastStatusCode: 500, LastError: "500 Internal Server Error", CreatedAt: created}
+)
+
+func (fakeWebhooks) Subscribe(_ context.Context, input modules.WebhookInput) (*modules.WebhookSubscription, error) {
+	sub := hook
+	sub.ID, sub.URL, sub.EventTypes = 2, input.URL, input.EventTypes
+	return &sub, nil
+}
+
+func (fakeWebhooks) ListSubscriptions(context.Context) ([]modules.WebhookSubscription, error) {
+	return []modules.WebhookSubscription{hook}, nil
+}
+
+func (fakeWebhooks) GetSubscription(_ context.Context, id int64) (*modules.WebhookSubscription, error) {
+	if id != hook.ID {
+		return nil, apperrors.ErrNotFound
+	}
+	sub := hook
+	return &sub, nil
+}
+
+func (fakeWebhooks) Unsubscribe(context.Context, int64) error { return nil }
+
+func (fakeWebhooks) ListDeliveries(context.Context, int64, modules.DeliveryFilter) ([]modules.WebhookDelivery, error) {
+	return []modules.WebhookDelivery{delivery}, nil
+}
+
+func (fakeWebhooks) GetDelivery(_ context.Context, subscriptionID, id int64) (*modules.WebhookDelivery, error) {
+	if subscriptionID != hook.ID || id != delivery.ID {
+		return nil, apperrors.ErrNotFound
+	}
+	d := delivery
+	return &d, nil
+}
+
+func (fakeWebhooks) ReplayDelivery(context.Context, int64, int64) (*modules.WebhookDelivery, error) {
+	replayOf := delivery.ID
+	return &modules.WebhookDelivery{ID: 2, SubscriptionID: 1, EventID: 1, EventType: modules.EventUserCreated,
+		Status: modules.DeliveryPending, NextAttemptAt: created, ReplayOf: &replayOf, CreatedAt: created}, nil
+}
+
+func newServer(t *testing.T, validateResponses bool) *httptest.Server {
+	t.Helper()
+	v := openapi.NewValidator(loadSpec(t), validateResponses)
+	gqlHandler, err := gql.NewHandler(fakeUsers{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A closed broker ends event streams right after their headers.
+	broker := events.NewBroker(nil)
+	broker.Close()
+	hs := router.Handlers{
+		Users:      handler.NewUserHandler(fakeUsers{}),
+		Webhooks:   handler.NewWebhookHandler(fakeWebhooks{}),
+		UserEvents: handler.NewUserEventsHandler(fakeUsers{}, broker, time.Minute),
+		GraphQL:    gqlHandler,
+	}
+	srv := httptest.NewServer(router.NewRouter(hs, apiKey, v))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func do(t *testing.T, srv *httptest.Server, method, path, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-API-KEY", apiKey)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+// TestHandlersMatchSpec runs every route through response validation, so a
+// handler drifting from its spec entry fails with a 500.
+func TestHandlersMatchSpec(t *testing.T) {
+	srv := newServer(t, true)
+	tests := []struct {
+		pattern, path, body string
+		status              int
+	}{
+		{"GET /health", "/health", "", http.StatusOK},
+		{"GET /openapi.json", "/openapi.json", "", http.StatusOK},
+		{"GET /users", "/users?limit=5", "", http.StatusOK},
+		{"GET /users", "/users?after_id=1&limit=5", "", http.StatusOK},
+		{"GET /users/search", "/users/search?q=ali", "", http.StatusOK},
+		{"GET /users/{id}", "/users/1", "", http.StatusOK},
+		{"GET /users/{id}", "/users/2", "", http.StatusNotFound},
+		{"POST /users", "/users", `{"name":"Bob","email":"bob@example.com"}`, http.StatusCreated},
+		{"POST /users/audit", "/users/audit", `{"name":"Bob","email":"bob@example.com"}`, http.StatusCreated},
+		{"PUT /users/{id}", "/users/1", `{"name":"Alicia","email":"alice@example.com"}`, http.StatusOK},
+		{"DELETE /users/{id}", "/users/1", "", http.StatusNoContent},
+		{"POST /users/{id}/restore", "/users/1/restore", "", http.StatusOK},
+		{"POST /users/{id}/restore", "/users/2/restore", "", http.StatusNotFound},
+		{"GET /audit-logs", "/audit-logs?user_id=1&after_seq=0&limit=10", "", http.StatusOK},
+		{"GET /users/events", "/users/events", "", http.StatusOK},
+		{"GET /debug/vars", "/debug/vars", "", http.StatusOK},
+		{"GET /graphql", "/graphql?query=" + url.QueryEscape("{ user(id: 1) { name auditLogs { action } } }"), "", http.StatusOK},
+		{"POST /graphql", "/graphql", `{"query":"{ users(first: 1) { totalCount } }"}`, http.StatusOK},
+		{"POST /webhooks", "/webhooks", `{"url":"https://example.com/hooks/users","event_types":["UserCreated"],"secret":"0123456789abcdef"}`, http.StatusCreated},
+		{"GET /webhooks", "/webhooks", "", http.StatusOK},
+		{"GET /webhooks/{id}", "/webhooks/1", "", http.StatusOK},
+		{"GET /webhooks/{id}", "/webhooks/2", "", http.StatusNotFound},
+		{"DELETE /webhooks/{id}", "/webhooks/1", "", http.StatusNoContent},
+		{"GET /webhooks/{id}/deliveries", "/webhooks/1/deliveries?status=dead&limit=10", "", http.StatusOK},
+		{"GET /webhooks/{id}/deliveries/{deliveryID}", "/webhooks/1/deliveries/1", "", http.StatusOK},
+		{"GET /webhooks/{id}/deliveries/{deliveryID}", "/webhooks/1/deliveries/2", "", http.StatusNotFound},
+		{"POST /webhooks/{id}/deliveries/{deliveryID}/replay", "/webhooks/1/deliveries/1/replay", "", http.StatusAccepted},
+	}
+
+	covered := map[string]bool{}
+	for _, tt := range tests {
+		covered[tt.pattern] = true
+		method, _, _ := strings.Cut(tt.pattern, " ")
+		t.Run(method+" "+tt.path, func(t *testing.T) {
+			if resp := do(t, srv, method, tt.path, tt.body); resp.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+		})
+	}
+	for _, pattern := range router.Patterns() {
+		if !covered[pattern] {
+			t.Errorf("route %s is not checked against the spec", pattern)
+		}
+	}
+}
+
+func TestValidatorRejectsBadRequests(t *testing.T) {
+	srv := newServer(t, false)
+	tests := []struct {
+		name, method, path, body string
+	}{
+		{"missing field", "POST", "/users", `{"name":"Bob"}`},
+		{"unknown field", "POST", "/users", `{"name":"Bob","email":"bob@example.com","admin":true}`},
+		{"wrong type", "PUT", "/users/1", `{"name":1,"email":"bob@example.com"}`},
+		{"bad path parameter", "GET", "/users/abc", ""},
+		{"missing query parameter", "GET", "/users/search", ""},
+		{"negative after_id", "GET", "/users?after_id=-1", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if resp := do(t, srv, tt.method, tt.path, tt.body); resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestValidatorRejectsResponsesOffSpec(t *testing.T) {
+	offSpec := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"one","nickname":"Alice"}`))
+	})
+	for _, validateResponses := range []bool{true, false} {
+		h := openapi.NewValidator(loadSpec(t), validateResponses).Middleware(offSpec)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/users/1", nil))
+
+		want := http.StatusOK
+		if validateResponses {
+			want = http.StatusInternalServerError
+		}
+		if rec.Code != want {
+			t.Errorf("validateResponses=%t: status = %d, want %d", validateResponses, rec.Code, want)
+		}
+	}
+}
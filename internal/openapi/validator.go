@@ -0,0 +1,233 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// maxValidatedBody bounds how much of a request body is buffered for
+// validation; larger bodies are passed on for the handler to reject.
+const maxValidatedBody = 1 << 20
+
+// Validator checks requests, and optionally responses, against the spec.
+// Requests for paths the spec does not describe are passed through.
+type Validator struct {
+	spec      *Spec
+	responses bool
+}
+
+func NewValidator(spec *Spec, validateResponses bool) *Validator {
+	return &Validator{spec: spec, responses: validateResponses}
+}
+
+func (v *Validator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op, pathParams := v.spec.find(r.Method, r.URL.Path)
+		if op == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if status, err := v.validateRequest(op, pathParams, r); err != nil {
+			writeError(w, status, err.Error())
+			return
+		}
+		if !v.responses || op.streaming {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &recorder{header: http.Header{}, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		if err := op.validateResponse(rec); err != nil {
+			log.Printf("openapi: %s %s: response violates spec: %v", r.Method, r.URL.Path, err)
+			writeError(w, http.StatusInternalServerError, "response does not match the API specification")
+			return
+		}
+		rec.flush(w)
+	})
+}
+
+func (v *Validator) validateRequest(op *operation, pathParams map[string]string, r *http.Request) (int, error) {
+	query := r.URL.Query()
+	for _, p := range op.params {
+		var raw string
+		var present bool
+		switch p.in {
+		case "path":
+			raw, present = pathParams[p.name]
+		case "query":
+			present = query.Has(p.name)
+			raw = query.Get(p.name)
+		case "header":
+			raw = r.Header.Get(p.name)
+			present = raw != ""
+		default:
+			continue
+		}
+		if !present {
+			if p.required {
+				return http.StatusBadRequest, fmt.Errorf("%s parameter %q is required", p.in, p.name)
+			}
+			continue
+		}
+		val, err := parseParam(p.typ, raw)
+		if err == nil && p.schema != nil {
+			err = describe(p.schema.Validate(val))
+		}
+		if err != nil {
+			return http.StatusBadRequest, fmt.Errorf("%s parameter %q %v", p.in, p.name, err)
+		}
+	}
+
+	if len(op.bodyTypes) == 0 {
+		return 0, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxValidatedBody+1))
+	if err != nil {
+		return http.StatusBadRequest, errors.New("could not read request body")
+	}
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	if len(body) > maxValidatedBody {
+		return 0, nil
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		if op.bodyNeeded {
+			return http.StatusBadRequest, errors.New("request body is required")
+		}
+		return 0, nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if !contains(op.bodyTypes, mediaType) {
+		return http.StatusUnsupportedMediaType, fmt.Errorf("Content-Type must be %s, got %q", strings.Join(op.bodyTypes, " or "), r.Header.Get("Content-Type"))
+	}
+	if op.body == nil {
+		return 0, nil
+	}
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(body))
+	if err != nil {
+		// Leave malformed JSON to the handler, which reports where it breaks.
+		return 0, nil
+	}
+	if err := describe(op.body.Validate(doc)); err != nil {
+		return http.StatusBadRequest, fmt.Errorf("request body %v", err)
+	}
+	return 0, nil
+}
+
+func (op *operation) validateResponse(rec *recorder) error {
+	resp, ok := op.responses[strconv.Itoa(rec.status)]
+	if !ok {
+		resp = op.defaultResp
+	}
+	if resp == nil {
+		return fmt.Errorf("status %d is not declared", rec.status)
+	}
+	if len(resp.schemas) == 0 {
+		if rec.body.Len() > 0 {
+			return fmt.Errorf("status %d declares no body", rec.status)
+		}
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(rec.header.Get("Content-Type"))
+	schema, ok := resp.schemas[mediaType]
+	if !ok {
+		return fmt.Errorf("content type %q is not declared for status %d", mediaType, rec.status)
+	}
+	if schema == nil || mediaType != "application/json" {
+		return nil
+	}
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(rec.body.Bytes()))
+	if err != nil {
+		return fmt.Errorf("body is not valid JSON: %w", err)
+	}
+	if err := describe(schema.Validate(doc)); err != nil {
+		return fmt.Errorf("body %v", err)
+	}
+	return nil
+}
+
+var printer = message.NewPrinter(language.English)
+
+// describe flattens a schema validation error into one line per violation.
+func describe(err error) error {
+	var verr *jsonschema.ValidationError
+	if !errors.As(err, &verr) {
+		return err
+	}
+	var msgs []string
+	var walk func(*jsonschema.ValidationError)
+	walk = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) > 0 {
+			for _, c := range e.Causes {
+				walk(c)
+			}
+			return
+		}
+		msg := e.ErrorKind.LocalizedString(printer)
+		if len(e.InstanceLocation) > 0 {
+			msg = fmt.Sprintf("at %q: %s", "/"+strings.Join(e.InstanceLocation, "/"), msg)
+		}
+		msgs = append(msgs, msg)
+	}
+	walk(verr)
+	return errors.New("is invalid: " + strings.Join(msgs, "; "))
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+// recorder buffers a response so it can be validated before it is sent.
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+	wrote  bool
+}
+
+func (r *recorder) Header() http.Header { return r.header }
+
+func (r *recorder) WriteHeader(status int) {
+	if !r.wrote {
+		r.status, r.wrote = status, true
+	}
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.wrote = true
+	return r.body.Write(b)
+}
+
+func (r *recorder) flush(w http.ResponseWriter) {
+	for k, v := range r.header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(r.status)
+	w.Write(r.body.Bytes())
+}
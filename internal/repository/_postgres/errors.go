@@ -0,0 +1,31 @@
+package _postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"practice4/practice-4/pkg/apperrors"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// WrapErr prefixes err with op and tags timeouts, unique violations and
+// serialization failures or deadlocks with the matching apperrors sentinel.
+func WrapErr(op string, err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%s: %w: %w", op, apperrors.ErrTimeout, err)
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case pgerrcode.QueryCanceled:
+			return fmt.Errorf("%s: %w: %w", op, apperrors.ErrTimeout, err)
+		case pgerrcode.UniqueViolation:
+			return fmt.Errorf("%s: %w: %w", op, apperrors.ErrConflict, err)
+		case pgerrcode.SerializationFailure, pgerrcode.DeadlockDetected:
+			return fmt.Errorf("%s: %w: %w", op, apperrors.ErrTransient, err)
+		}
+	}
+	return fmt.Errorf("%s: %w", op, err)
+}
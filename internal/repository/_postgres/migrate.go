@@ -0,0 +1,168 @@
+package _postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"practice4/practice-4/database"
+	"practice4/practice-4/pkg/modules"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/pgx/v5"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/jackc/pgx/v5"
+)
+
+// MigrationsDir is where "migrate create" writes new files in the source tree;
+// at runtime migrations are read from the embedded database.Migrations.
+const MigrationsDir = "database/migrations"
+
+var migrationLockID = int64(crc32.ChecksumIEEE([]byte("practice4/schema_migrations")))
+
+// DirtyError reports that a previous migration failed part-way and the schema
+// needs manual repair before migrating again.
+type DirtyError struct {
+	Version uint
+	Prev    int
+}
+
+func (e *DirtyError) Error() string {
+	return fmt.Sprintf("database is dirty at version %d: a migration failed part-way. "+
+		"Repair the schema by hand, then run \"api migrate force %d\" if migration %d is fully applied, "+
+		"or \"api migrate force %d\" if it is not", e.Version, e.Version, e.Version, e.Prev)
+}
+
+// MigrationsFS returns the embedded migrations rooted at their directory.
+func MigrationsFS() fs.FS {
+	sub, err := fs.Sub(database.Migrations, "migrations")
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
+
+type migrateLogger struct{}
+
+func (migrateLogger) Printf(format string, v ...any) { log.Printf("migrate: "+format, v...) }
+func (migrateLogger) Verbose() bool                  { return false }
+
+// NewMigrator opens a golang-migrate instance for the configured database
+// after linting the embedded migrations. Callers must Close it.
+func NewMigrator(cfg *modules.PostgreConfig) (*migrate.Migrate, error) {
+	if err := database.Lint(MigrationsFS()); err != nil {
+		return nil, fmt.Errorf("migration lint failed:\n%w", err)
+	}
+
+	dsn, err := DSN(cfg)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, err
+	}
+	u.Scheme = "pgx5"
+
+	src, err := iofs.New(database.Migrations, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("open embedded migrations: %w", err)
+	}
+	m, err := migrate.NewWithSourceInstance("iofs", src, u.String())
+	if err != nil {
+		return nil, fmt.Errorf("open migrations: %w", err)
+	}
+	m.Log = migrateLogger{}
+	return m, nil
+}
+
+// StopOnCancel asks m to stop after the current migration once ctx is done.
+func StopOnCancel(ctx context.Context, m *migrate.Migrate) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			m.GracefulStop <- true
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
+
+// WithMigrationLock runs fn while holding a session-level advisory lock on a
+// dedicated connection, so replicas starting together migrate one at a time.
+func WithMigrationLock(ctx context.Context, cfg *modules.PostgreConfig, fn func() error) error {
+	dsn, err := DSN(cfg)
+	if err != nil {
+		return err
+	}
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		return fmt.Errorf("migration lock: %w", err)
+	}
+	defer conn.Close(context.Background())
+
+	log.Println("migrate: waiting for migration lock")
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		return fmt.Errorf("migration lock: %w", err)
+	}
+	defer conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockID)
+
+	return fn()
+}
+
+// CheckDirty returns a *DirtyError when the last migration did not complete.
+func CheckDirty(m *migrate.Migrate) error {
+	version, dirty, err := m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !dirty {
+		return nil
+	}
+	return dirtyError(version)
+}
+
+// dirtyError builds the *DirtyError for version, looking up the migration
+// before it so the hint names a version that can be forced.
+func dirtyError(version uint) error {
+	prev := -1
+	src, err := iofs.New(database.Migrations, "migrations")
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	if p, err := src.Prev(version); err == nil {
+		prev = int(p)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return &DirtyError{Version: version, Prev: prev}
+}
+
+// AutoMigrate applies all pending up migrations under the migration lock.
+func AutoMigrate(ctx context.Context, cfg *modules.PostgreConfig) error {
+	return WithMigrationLock(ctx, cfg, func() error {
+		m, err := NewMigrator(cfg)
+		if err != nil {
+			return err
+		}
+		defer m.Close()
+		defer StopOnCancel(ctx, m)()
+
+		if err := CheckDirty(m); err != nil {
+			return err
+		}
+		if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+			return fmt.Errorf("migrate up: %w", err)
+		}
+		return nil
+	})
+}
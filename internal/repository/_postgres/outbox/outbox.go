@@ -0,0 +1,76 @@
+package outbox
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"practice4/practice-4/internal/repository/_postgres"
+	"practice4/practice-4/pkg/modules"
+)
+
+type Repository struct {
+	db *_postgres.Dialect
+}
+
+func NewOutboxRepository(db *_postgres.Dialect) *Repository {
+	return &Repository{db: db}
+}
+
+type eventRow struct {
+	ID          int64     `db:"id"`
+	Type        string    `db:"event_type"`
+	AggregateID int64     `db:"aggregate_id"`
+	Payload     []byte    `db:"payload"`
+	CreatedAt   time.Time `db:"created_at"`
+	Attempts    int       `db:"attempts"`
+}
+
+// ClaimDue leases up to limit due events, oldest first, by pushing their
+// next attempt past the lease, so concurrent relays skip them while they are
+// published and a relay that dies lets them fall due again. No transaction
+// or lock outlives the statement.
+func (r *Repository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]modules.Event, error) {
+	var rows []eventRow
+	err := r.db.Conn(ctx).SelectContext(ctx, &rows, `WITH due AS (
+			SELECT id FROM outbox
+			WHERE published_at IS NULL AND dead_at IS NULL AND next_attempt_at <= NOW()
+			ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED)
+		UPDATE outbox o SET next_attempt_at = NOW() + make_interval(secs => $2)
+		FROM due WHERE o.id = due.id
+		RETURNING o.id, o.event_type, o.aggregate_id, o.payload::text AS payload, o.created_at, o.attempts`,
+		limit, lease.Seconds())
+	if err != nil {
+		return nil, _postgres.WrapErr("ClaimDue", err)
+	}
+	// RETURNING follows no particular order.
+	sort.Slice(rows, func(i, j int) bool { return rows[i].ID < rows[j].ID })
+
+	events := make([]modules.Event, len(rows))
+	for i, row := range rows {
+		events[i] = modules.Event{
+			ID:          row.ID,
+			Type:        row.Type,
+			AggregateID: row.AggregateID,
+			Payload:     row.Payload,
+			CreatedAt:   row.CreatedAt,
+			Attempts:    row.Attempts,
+		}
+	}
+	return events, nil
+}
+
+// RecordAttempt saves the outcome of publishing a claimed event: whether it
+// was published or is dead, its attempts, next attempt and last error.
+func (r *Repository) RecordAttempt(ctx context.Context, e *modules.Event) error {
+	// Times are computed by the database so they agree with NOW() in ClaimDue.
+	_, err := r.db.Conn(ctx).ExecContext(ctx, `UPDATE outbox SET attempts = $1, last_error = NULLIF($2, ''),
+		next_attempt_at = NOW() + make_interval(secs => $3),
+		published_at = CASE WHEN $4::boolean THEN NOW() END, dead_at = CASE WHEN $5::boolean THEN NOW() END
+		WHERE id = $6 AND published_at IS NULL AND dead_at IS NULL`,
+		e.Attempts, e.LastError, max(time.Until(e.NextAttemptAt), 0).Seconds(), e.Published, e.Dead, e.ID)
+	if err != nil {
+		return _postgres.WrapErr("RecordAttempt", err)
+	}
+	return nil
+}
@@ -2,71 +2,168 @@ package _postgres
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net"
+	"net/url"
 	"practice4/practice-4/pkg/modules"
-	"time"
+	"strconv"
+	"sync"
+	"sync/atomic"
 
-	"github.com/golang-migrate/migrate/v4"
-	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
 )
 
 type Dialect struct {
-	DB *sqlx.DB
+	DB         *sqlx.DB
+	connConfig *pgx.ConnConfig
+
+	replicas    []*replica
+	nextReplica atomic.Uint64
+	stopHealth  context.CancelFunc
+	healthDone  sync.WaitGroup
 }
 
-func NewPGXDialect(ctx context.Context, cfg *modules.PostgreConfig) *Dialect {
-	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-		cfg.Host, cfg.Port, cfg.Username, cfg.Password, cfg.DBName, cfg.SSLMode)
+func NewPGXDialect(ctx context.Context, cfg *modules.PostgreConfig) (*Dialect, error) {
+	dsn, err := DSN(cfg)
+	if err != nil {
+		return nil, err
+	}
+	connConfig, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("parse database config: %w", err)
+	}
+	connConfig.DefaultQueryExecMode = pgx.QueryExecModeCacheStatement
 
 	var db *sqlx.DB
-	var err error
-	maxRetries := 30
-	retryDelay := 2 * time.Second
-
-	for i := 0; i < maxRetries; i++ {
-		db, err = sqlx.Connect("postgres", dsn)
-		if err == nil {
-			err = db.PingContext(ctx)
-			if err == nil {
-				log.Println("Database connection established successfully")
-				break
-			}
+	err = retry(ctx, cfg.Retry, func() error {
+		db = sqlx.NewDb(stdlib.OpenDB(*connConfig), "pgx")
+		if err := db.PingContext(ctx); err != nil {
+			db.Close()
+			return err
 		}
-		log.Printf("Failed to connect to database (attempt %d/%d): %v", i+1, maxRetries, err)
-		time.Sleep(retryDelay)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("connect to database: %w", err)
 	}
+	log.Println("Database connection established successfully")
 
-	if err != nil {
-		log.Fatalf("Could not connect to database after %d attempts: %v", maxRetries, err)
+	configurePool(db, cfg)
+
+	d := &Dialect{
+		DB:         db,
+		connConfig: connConfig,
+	}
+	if err := d.openReplicas(cfg); err != nil {
+		d.Close()
+		return nil, err
 	}
+	return d, nil
+}
 
-	AutoMigrate(cfg)
+func configurePool(db *sqlx.DB, cfg *modules.PostgreConfig) {
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+}
+
+// DSN builds an escaped postgres:// connection URL from cfg. A configured
+// URL is used as the base, with application_name and statement_timeout added
+// unless it already sets them.
+func DSN(cfg *modules.PostgreConfig) (string, error) {
+	var u *url.URL
+	if cfg.URL != "" {
+		parsed, err := url.Parse(cfg.URL)
+		if err != nil {
+			return "", fmt.Errorf("parse database URL: %w", err)
+		}
+		u = parsed
+	} else {
+		u = &url.URL{
+			Scheme: "postgres",
+			User:   url.UserPassword(cfg.Username, cfg.Password),
+			Host:   net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+			Path:   "/" + cfg.DBName,
+		}
+	}
 
-	return &Dialect{
-		DB: db,
+	q := u.Query()
+	setDefault := func(key, val string) {
+		if val != "" && !q.Has(key) {
+			q.Set(key, val)
+		}
+	}
+	if cfg.URL == "" {
+		setDefault("sslmode", cfg.SSLMode)
+	}
+	setDefault("application_name", cfg.ApplicationName)
+	if cfg.StatementTimeout > 0 {
+		setDefault("statement_timeout", strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10))
 	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
 }
 
-func AutoMigrate(cfg *modules.PostgreConfig) {
-	sourceURL := "file://database/migrations"
-	databaseURL := fmt.Sprintf("postgres://%s:%s@%s:%d/%s?sslmode=%s",
-		cfg.Username, cfg.Password, cfg.Host, cfg.Port, cfg.DBName, cfg.SSLMode)
+// CopyFrom bulk-loads rows into table using the COPY protocol.
+func (d *Dialect) CopyFrom(ctx context.Context, table string, columns []string, rows [][]any) (int64, error) {
+	conn, err := d.DB.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	var n int64
+	err = conn.Raw(func(driverConn any) error {
+		pgxConn := driverConn.(*stdlib.Conn).Conn()
+		n, err = pgxConn.CopyFrom(ctx, pgx.Identifier{table}, columns, pgx.CopyFromRows(rows))
+		return err
+	})
+	return n, err
+}
 
-	m, err := migrate.New(sourceURL, databaseURL)
+// Listen subscribes to channel on a dedicated connection outside the pool and
+// calls handle for every notification until ctx is cancelled.
+func (d *Dialect) Listen(ctx context.Context, channel string, handle func(*pgconn.Notification)) error {
+	conn, err := pgx.ConnectConfig(ctx, d.connConfig)
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("listen %s: %w", channel, err)
 	}
+	defer conn.Close(context.Background())
 
-	err = m.Up()
-	if err != nil && err != migrate.ErrNoChange {
-		panic(err)
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+		return fmt.Errorf("listen %s: %w", channel, err)
+	}
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("listen %s: %w", channel, err)
+		}
+		handle(n)
 	}
 }
 
+func (d *Dialect) Notify(ctx context.Context, channel, payload string) error {
+	_, err := d.DB.ExecContext(ctx, "SELECT pg_notify($1, $2)", channel, payload)
+	return err
+}
+
 func (d *Dialect) Close() error {
-	return d.DB.Close()
+	if d.stopHealth != nil {
+		d.stopHealth()
+		d.healthDone.Wait()
+	}
+	errs := []error{d.DB.Close()}
+	for _, r := range d.replicas {
+		errs = append(errs, r.db.Close())
+	}
+	return errors.Join(errs...)
 }
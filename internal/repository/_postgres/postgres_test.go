@@ -0,0 +1,122 @@
+package _postgres
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"practice4/practice-4/pkg/modules"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func TestDSN(t *testing.T) {
+	fields := modules.PostgreConfig{Host: "db", Port: 5432, Username: "app", Password: "secret", DBName: "users", SSLMode: "disable"}
+	with := func(edit func(*modules.PostgreConfig)) *modules.PostgreConfig {
+		cfg := fields
+		edit(&cfg)
+		return &cfg
+	}
+
+	for _, tc := range []struct {
+		name string
+		cfg  *modules.PostgreConfig
+		// want is the exact DSN when set; the parsed fields are checked
+		// either way.
+		want                     string
+		host, user, pass, dbname string
+		port                     uint16
+		params                   map[string]string
+	}{
+		{
+			name: "fields",
+			cfg:  &fields,
+			want: "postgres://app:secret@db:5432/users?sslmode=disable",
+			host: "db", port: 5432, user: "app", pass: "secret", dbname: "users",
+		},
+		{
+			name: "reserved characters in credentials",
+			cfg:  with(func(c *modules.PostgreConfig) { c.Username = "us@r:x"; c.Password = "p@ss:w/rd?#%20" }),
+			host: "db", port: 5432, user: "us@r:x", pass: "p@ss:w/rd?#%20", dbname: "users",
+		},
+		{
+			name: "reserved characters in database name",
+			cfg:  with(func(c *modules.PostgreConfig) { c.DBName = "my db?x" }),
+			host: "db", port: 5432, user: "app", pass: "secret", dbname: "my db?x",
+		},
+		{
+			name: "IPv6 host",
+			cfg:  with(func(c *modules.PostgreConfig) { c.Host = "::1"; c.Port = 6432 }),
+			want: "postgres://app:secret@[::1]:6432/users?sslmode=disable",
+			host: "::1", port: 6432, user: "app", pass: "secret", dbname: "users",
+		},
+		{
+			name: "URL takes precedence over fields",
+			cfg:  with(func(c *modules.PostgreConfig) { c.URL = "postgres://other:pw@primary:5433/main" }),
+			want: "postgres://other:pw@primary:5433/main",
+			host: "primary", port: 5433, user: "other", pass: "pw", dbname: "main",
+		},
+		{
+			name: "defaults added",
+			cfg: with(func(c *modules.PostgreConfig) {
+				c.ApplicationName = "practice4-api"
+				c.StatementTimeout = 1500 * time.Millisecond
+			}),
+			want: "postgres://app:secret@db:5432/users?application_name=practice4-api&sslmode=disable&statement_timeout=1500",
+			host: "db", port: 5432, user: "app", pass: "secret", dbname: "users",
+			params: map[string]string{"application_name": "practice4-api", "statement_timeout": "1500"},
+		},
+		{
+			name: "URL parameters kept over defaults",
+			cfg: with(func(c *modules.PostgreConfig) {
+				c.URL = "postgres://app@db/users?application_name=job&statement_timeout=90000&sslmode=require"
+				c.ApplicationName = "practice4-api"
+				c.StatementTimeout = time.Second
+			}),
+			want: "postgres://app@db/users?application_name=job&sslmode=require&statement_timeout=90000",
+			host: "db", port: 5432, user: "app", dbname: "users",
+			params: map[string]string{"application_name": "job", "statement_timeout": "90000"},
+		},
+		{
+			name: "defaults added to URL",
+			cfg: with(func(c *modules.PostgreConfig) {
+				c.URL = "postgres://app@db/users"
+				c.ApplicationName = "practice4-api"
+			}),
+			want: "postgres://app@db/users?application_name=practice4-api",
+			host: "db", port: 5432, user: "app", dbname: "users",
+			params: map[string]string{"application_name": "practice4-api"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dsn, err := DSN(tc.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.want != "" && dsn != tc.want {
+				t.Errorf("DSN = %q, want %q", dsn, tc.want)
+			}
+			got, err := pgx.ParseConfig(dsn)
+			if err != nil {
+				t.Fatalf("ParseConfig(%q): %v", dsn, err)
+			}
+			if got.Host != tc.host || got.Port != tc.port || got.User != tc.user || got.Password != tc.pass || got.Database != tc.dbname {
+				t.Errorf("DSN %q parses as %s:%d user %q password %q database %q", dsn, got.Host, got.Port, got.User, got.Password, got.Database)
+			}
+			for k, v := range tc.params {
+				if got.RuntimeParams[k] != v {
+					t.Errorf("%s = %q, want %q", k, got.RuntimeParams[k], v)
+				}
+			}
+			if tc.cfg.StatementTimeout == 0 && tc.params["statement_timeout"] == "" && strings.Contains(dsn, "statement_timeout") {
+				t.Errorf("DSN %q sets statement_timeout without one configured", dsn)
+			}
+		})
+	}
+}
+
+func TestDSNRejectsBadURL(t *testing.T) {
+	if _, err := DSN(&modules.PostgreConfig{URL: "postgres://app@db:port/x"}); err == nil {
+		t.Error("DSN = nil error, want the URL rejected")
+	}
+}
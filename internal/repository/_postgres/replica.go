@@ -0,0 +1,183 @@
+package _postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"practice4/practice-4/pkg/modules"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/jmoiron/sqlx"
+)
+
+const replicaPingTimeout = 2 * time.Second
+
+type replica struct {
+	name    string
+	db      *sqlx.DB
+	healthy atomic.Bool
+}
+
+func (r *replica) setHealthy(ok bool, err error) {
+	if r.healthy.Swap(ok) == ok {
+		return
+	}
+	if ok {
+		log.Printf("replica %s is healthy, routing reads to it", r.name)
+	} else {
+		log.Printf("replica %s is unavailable, routing its reads to the primary: %v", r.name, err)
+	}
+}
+
+func (r *replica) check(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, replicaPingTimeout)
+	defer cancel()
+	err := r.db.PingContext(ctx)
+	r.setHealthy(err == nil, err)
+}
+
+// openReplicas opens a pool per replica URL without waiting for them: a
+// replica only takes reads once a health check has reached it.
+func (d *Dialect) openReplicas(cfg *modules.PostgreConfig) error {
+	for _, replicaURL := range cfg.ReplicaURLs {
+		replicaCfg := *cfg
+		replicaCfg.URL = replicaURL
+		dsn, err := DSN(&replicaCfg)
+		if err != nil {
+			return err
+		}
+		connConfig, err := pgx.ParseConfig(dsn)
+		if err != nil {
+			return fmt.Errorf("parse replica config: %w", err)
+		}
+		connConfig.DefaultQueryExecMode = pgx.QueryExecModeCacheStatement
+
+		db := sqlx.NewDb(stdlib.OpenDB(*connConfig), "pgx")
+		configurePool(db, cfg)
+		d.replicas = append(d.replicas, &replica{name: connConfig.Host, db: db})
+	}
+	if len(d.replicas) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d.stopHealth = cancel
+	for _, r := range d.replicas {
+		r.check(ctx)
+	}
+	d.healthDone.Add(1)
+	go func() {
+		defer d.healthDone.Done()
+		ticker := time.NewTicker(cfg.ReplicaHealthInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				var wg sync.WaitGroup
+				for _, r := range d.replicas {
+					wg.Add(1)
+					go func(r *replica) {
+						defer wg.Done()
+						r.check(ctx)
+					}(r)
+				}
+				wg.Wait()
+			}
+		}
+	}()
+	return nil
+}
+
+// Read runs a read-only query with a replica when one is healthy and ctx
+// does not require the primary or carry a transaction. If the replica fails
+// at the connection level it is marked unhealthy and fn is retried on the
+// primary.
+func (d *Dialect) Read(ctx context.Context, fn func(q Querier) error) error {
+	if tx, ok := txFrom(ctx); ok {
+		return fn(tx)
+	}
+	r := d.pickReplica(ctx)
+	if r == nil {
+		return fn(d.DB)
+	}
+	err := fn(r.db)
+	if !isConnectionError(ctx, err) {
+		return err
+	}
+	r.setHealthy(false, err)
+	return fn(d.DB)
+}
+
+func (d *Dialect) pickReplica(ctx context.Context) *replica {
+	if len(d.replicas) == 0 || readsFromPrimary(ctx) {
+		return nil
+	}
+	start := d.nextReplica.Add(1)
+	for i := range d.replicas {
+		r := d.replicas[(start+uint64(i))%uint64(len(d.replicas))]
+		if r.healthy.Load() {
+			return r
+		}
+	}
+	return nil
+}
+
+// isConnectionError reports whether err means the server could not be
+// reached at all. Query errors, scan errors, no rows and the caller giving
+// up all leave the replica in rotation.
+func isConnectionError(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil || errors.Is(err, sql.ErrNoRows) {
+		return false
+	}
+	var connectErr *pgconn.ConnectError
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) ||
+		errors.As(err, &connectErr) ||
+		errors.As(err, &netErr) ||
+		pgconn.SafeToRetry(err)
+}
+
+type primaryKey struct{}
+
+type writeTracker struct {
+	wrote atomic.Bool
+}
+
+// WithPrimary returns a context whose reads go to the primary.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+type trackerKey struct{}
+
+// TrackWrites returns a context that remembers mutations made with it, so
+// later reads with it go to the primary and see them despite replica lag.
+func TrackWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, trackerKey{}, &writeTracker{})
+}
+
+// MarkWritten records a committed mutation for contexts from TrackWrites.
+func MarkWritten(ctx context.Context) {
+	if t, ok := ctx.Value(trackerKey{}).(*writeTracker); ok {
+		t.wrote.Store(true)
+	}
+}
+
+func readsFromPrimary(ctx context.Context) bool {
+	if primary, _ := ctx.Value(primaryKey{}).(bool); primary {
+		return true
+	}
+	t, ok := ctx.Value(trackerKey{}).(*writeTracker)
+	return ok && t.wrote.Load()
+}
@@ -0,0 +1,85 @@
+package _postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"practice4/practice-4/pkg/modules"
+	"time"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// retry calls fn until it succeeds, backing off exponentially with jitter
+// between attempts. It gives up once the next attempt would start after
+// policy.MaxElapsed, as soon as ctx is cancelled, or when fn fails in a way
+// another attempt cannot fix.
+func retry(ctx context.Context, policy modules.RetryPolicy, fn func() error) error {
+	interval := policy.InitialInterval
+	if interval <= 0 {
+		interval = 500 * time.Millisecond
+	}
+	maxInterval := policy.MaxInterval
+	if maxInterval < interval {
+		maxInterval = interval
+	}
+	deadline := time.Now().Add(policy.MaxElapsed)
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !retryable(err) {
+			return err
+		}
+
+		wait := jitter(interval)
+		if time.Now().Add(wait).After(deadline) {
+			return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+		log.Printf("Failed to connect to database (attempt %d, retrying in %s): %v", attempt, wait.Round(time.Millisecond), err)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		interval *= 2
+		if interval > maxInterval {
+			interval = maxInterval
+		}
+	}
+}
+
+// jitter returns a wait in [interval/2, interval], which keeps replicas from
+// retrying in lockstep.
+func jitter(interval time.Duration) time.Duration {
+	return interval/2 + time.Duration(rand.Int63n(int64(interval/2)+1))
+}
+
+// retryable reports whether a failed connection attempt may succeed later.
+// Errors the server answers with are final, such as a wrong password
+// (28P01) or a missing database (3D000), except those saying it is
+// unavailable for now: connection exceptions, too many connections and
+// other lack of resources, and starting up or shutting down. Failures to
+// reach the server at all are retried, since it may still be starting.
+func retryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgerrcode.IsConnectionException(pgErr.Code) ||
+			pgerrcode.IsInsufficientResources(pgErr.Code) ||
+			pgerrcode.IsOperatorIntervention(pgErr.Code) && pgErr.Code != pgerrcode.QueryCanceled
+	}
+	var parseErr *pgconn.ParseConfigError
+	return !errors.As(err, &parseErr)
+}
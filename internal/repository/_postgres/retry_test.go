@@ -0,0 +1,117 @@
+package _postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"practice4/practice-4/pkg/modules"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestJitterBounds(t *testing.T) {
+	for _, interval := range []time.Duration{time.Nanosecond, time.Millisecond, 500 * time.Millisecond, 10 * time.Second} {
+		for i := 0; i < 1000; i++ {
+			if wait := jitter(interval); wait < interval/2 || wait > interval {
+				t.Fatalf("jitter(%v) = %v, want within [%v, %v]", interval, wait, interval/2, interval)
+			}
+		}
+	}
+}
+
+func TestRetrySucceeds(t *testing.T) {
+	attempts := 0
+	policy := modules.RetryPolicy{InitialInterval: time.Millisecond, MaxInterval: time.Millisecond, MaxElapsed: time.Second}
+	err := retry(context.Background(), policy, func() error {
+		if attempts++; attempts < 3 {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	if err != nil || attempts != 3 {
+		t.Fatalf("retry = %v after %d attempts, want success on the third", err, attempts)
+	}
+}
+
+func TestRetryGivesUpAfterMaxElapsed(t *testing.T) {
+	const maxElapsed = 100 * time.Millisecond
+	policy := modules.RetryPolicy{InitialInterval: 10 * time.Millisecond, MaxInterval: 20 * time.Millisecond, MaxElapsed: maxElapsed}
+	refused := errors.New("connection refused")
+	attempts := 0
+	start := time.Now()
+	err := retry(context.Background(), policy, func() error {
+		attempts++
+		return refused
+	})
+	if elapsed := time.Since(start); elapsed > maxElapsed {
+		t.Errorf("retry took %v, want at most MaxElapsed %v", elapsed, maxElapsed)
+	}
+	if !errors.Is(err, refused) {
+		t.Errorf("retry = %v, want it to wrap the last error", err)
+	}
+	if attempts < 3 {
+		t.Errorf("made %d attempts, want several within MaxElapsed", attempts)
+	}
+}
+
+func TestRetryStopsOnCancel(t *testing.T) {
+	policy := modules.RetryPolicy{InitialInterval: time.Minute, MaxInterval: time.Minute, MaxElapsed: time.Hour}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	attempts := 0
+	start := time.Now()
+	err := retry(ctx, policy, func() error {
+		attempts++
+		return errors.New("connection refused")
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("retry = %v, want the context's error", err)
+	}
+	if attempts != 1 || time.Since(start) > time.Second {
+		t.Errorf("made %d attempts in %v, want 1 and an immediate return", attempts, time.Since(start))
+	}
+}
+
+func TestRetryFailsFastOnPermanentErrors(t *testing.T) {
+	pgErr := func(code string) error { return &pgconn.PgError{Code: code} }
+	for _, tc := range []struct {
+		name  string
+		err   error
+		retry bool
+	}{
+		{"wrong password", pgErr("28P01"), false},
+		{"missing database", pgErr("3D000"), false},
+		{"missing role", pgErr("28000"), false},
+		{"wrapped wrong password", fmt.Errorf("failed to connect: %w", pgErr("28P01")), false},
+		{"invalid config", &pgconn.ParseConfigError{}, false},
+		{"starting up", pgErr("57P03"), true},
+		{"admin shutdown", pgErr("57P01"), true},
+		{"too many connections", pgErr("53300"), true},
+		{"connection failure", pgErr("08006"), true},
+		{"unreachable", errors.New("dial tcp: connection refused"), true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			policy := modules.RetryPolicy{InitialInterval: time.Millisecond, MaxInterval: time.Millisecond, MaxElapsed: time.Second}
+			attempts := 0
+			err := retry(context.Background(), policy, func() error {
+				if attempts++; attempts < 3 {
+					return tc.err
+				}
+				return nil
+			})
+			want := 1
+			if tc.retry {
+				want = 3
+			}
+			if attempts != want {
+				t.Errorf("made %d attempts, want %d", attempts, want)
+			}
+			if !tc.retry && !errors.Is(err, tc.err) {
+				t.Errorf("retry = %v, want %v", err, tc.err)
+			}
+		})
+	}
+}
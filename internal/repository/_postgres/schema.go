@@ -0,0 +1,199 @@
+package _postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"practice4/practice-4/database"
+	"practice4/practice-4/pkg/modules"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const scratchSchema = "schema_check_expected"
+
+type SchemaDifference struct {
+	Kind     string `json:"kind"`
+	Table    string `json:"table"`
+	Name     string `json:"name,omitempty"`
+	Issue    string `json:"issue"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+}
+
+type SchemaReport struct {
+	Schema      string             `json:"schema"`
+	Version     uint               `json:"version"`
+	Drift       bool               `json:"drift"`
+	Differences []SchemaDifference `json:"differences"`
+}
+
+type schemaObject struct {
+	kind, table, name string
+}
+
+// CheckSchema compares the live schema with the one the migrations describe.
+// The expected schema is built by replaying the up migrations, up to the
+// version recorded in schema_migrations, into a scratch schema inside a
+// transaction that is always rolled back.
+func CheckSchema(ctx context.Context, cfg *modules.PostgreConfig) (*SchemaReport, error) {
+	dsn, err := DSN(cfg)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("schema check: %w", err)
+	}
+	defer conn.Close(context.Background())
+
+	var live string
+	if err := conn.QueryRow(ctx, "SELECT current_schema()").Scan(&live); err != nil {
+		return nil, fmt.Errorf("schema check: %w", err)
+	}
+	version, err := appliedVersion(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := database.UpMigrations(MigrationsFS())
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("schema check: %w", err)
+	}
+	defer tx.Rollback(context.Background())
+
+	actual, err := snapshotSchema(ctx, tx, live)
+	if err != nil {
+		return nil, err
+	}
+
+	setup := fmt.Sprintf("CREATE SCHEMA %[1]s; SET LOCAL search_path TO %[1]s, %[2]s",
+		pgx.Identifier{scratchSchema}.Sanitize(), pgx.Identifier{live}.Sanitize())
+	if _, err := tx.Exec(ctx, setup); err != nil {
+		return nil, fmt.Errorf("schema check: %w", err)
+	}
+	for _, m := range migrations {
+		if m.Version > version {
+			break
+		}
+		if _, err := tx.Exec(ctx, m.SQL); err != nil {
+			return nil, fmt.Errorf("schema check: replay migration %d_%s: %w", m.Version, m.Name, err)
+		}
+	}
+	expected, err := snapshotSchema(ctx, tx, scratchSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &SchemaReport{Schema: live, Version: version, Differences: diffSchemas(expected, actual)}
+	report.Drift = len(report.Differences) > 0
+	return report, nil
+}
+
+func appliedVersion(ctx context.Context, conn *pgx.Conn) (uint, error) {
+	var version int64
+	var dirty bool
+	err := conn.QueryRow(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	var pgErr *pgconn.PgError
+	if errors.Is(err, pgx.ErrNoRows) || (errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UndefinedTable) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("schema check: read migration version: %w", err)
+	}
+	if dirty {
+		return 0, dirtyError(uint(version))
+	}
+	return uint(version), nil
+}
+
+func snapshotSchema(ctx context.Context, tx pgx.Tx, schema string) (map[schemaObject]string, error) {
+	queries := []struct {
+		kind  string
+		query string
+	}{
+		{"table", `SELECT table_name, '', table_type FROM information_schema.tables
+			WHERE table_schema = $1 AND table_name <> 'schema_migrations'`},
+		{"column", `SELECT table_name, column_name,
+				data_type || CASE WHEN is_nullable = 'NO' THEN ' NOT NULL' ELSE '' END ||
+				COALESCE(' DEFAULT ' || column_default, '') ||
+				COALESCE(' GENERATED ALWAYS AS (' || generation_expression || ') STORED', '')
+			FROM information_schema.columns
+			WHERE table_schema = $1 AND table_name <> 'schema_migrations'`},
+		{"index", `SELECT tablename, indexname, indexdef FROM pg_indexes
+			WHERE schemaname = $1 AND tablename <> 'schema_migrations'`},
+		{"constraint", `SELECT rel.relname, con.conname, pg_get_constraintdef(con.oid)
+			FROM pg_constraint con
+			JOIN pg_class rel ON rel.oid = con.conrelid
+			JOIN pg_namespace ns ON ns.oid = rel.relnamespace
+			WHERE ns.nspname = $1 AND rel.relname <> 'schema_migrations'`},
+	}
+
+	objects := make(map[schemaObject]string)
+	for _, q := range queries {
+		rows, err := tx.Query(ctx, q.query, schema)
+		if err != nil {
+			return nil, fmt.Errorf("schema check: introspect %ss: %w", q.kind, err)
+		}
+		for rows.Next() {
+			var table, name, def string
+			if err := rows.Scan(&table, &name, &def); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("schema check: introspect %ss: %w", q.kind, err)
+			}
+			objects[schemaObject{q.kind, table, name}] = normalizeDefinition(def, schema)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("schema check: introspect %ss: %w", q.kind, err)
+		}
+	}
+	return objects, nil
+}
+
+// normalizeDefinition strips schema qualifiers so that definitions from the
+// live and scratch schemas compare equal.
+func normalizeDefinition(def, schema string) string {
+	for _, s := range []string{schema, scratchSchema} {
+		def = strings.ReplaceAll(def, pgx.Identifier{s}.Sanitize()+".", "")
+		def = strings.ReplaceAll(def, s+".", "")
+	}
+	return def
+}
+
+func diffSchemas(expected, actual map[schemaObject]string) []SchemaDifference {
+	diffs := []SchemaDifference{}
+	for obj, want := range expected {
+		got, ok := actual[obj]
+		switch {
+		case !ok:
+			diffs = append(diffs, SchemaDifference{Kind: obj.kind, Table: obj.table, Name: obj.name, Issue: "missing", Expected: want})
+		case got != want:
+			diffs = append(diffs, SchemaDifference{Kind: obj.kind, Table: obj.table, Name: obj.name, Issue: "changed", Expected: want, Actual: got})
+		}
+	}
+	for obj, got := range actual {
+		if _, ok := expected[obj]; !ok {
+			diffs = append(diffs, SchemaDifference{Kind: obj.kind, Table: obj.table, Name: obj.name, Issue: "unexpected", Actual: got})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		a, b := diffs[i], diffs[j]
+		if a.Table != b.Table {
+			return a.Table < b.Table
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Name < b.Name
+	})
+	return diffs
+}
@@ -0,0 +1,68 @@
+package _postgres
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeDefinition(t *testing.T) {
+	for _, tc := range []struct {
+		name, def, schema, want string
+	}{
+		{"live schema", "CREATE INDEX idx ON app.users USING btree (email)", "app", "CREATE INDEX idx ON users USING btree (email)"},
+		{"scratch schema", "CREATE INDEX idx ON schema_check_expected.users USING btree (email)", "app", "CREATE INDEX idx ON users USING btree (email)"},
+		{"quoted schema", `FOREIGN KEY (user_id) REFERENCES "My App".users(id)`, "My App", "FOREIGN KEY (user_id) REFERENCES users(id)"},
+		{"sequence default", "bigint NOT NULL DEFAULT nextval('app.users_id_seq'::regclass)", "app", "bigint NOT NULL DEFAULT nextval('users_id_seq'::regclass)"},
+		{"unqualified", "CHECK ((length(name) > 0))", "public", "CHECK ((length(name) > 0))"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := normalizeDefinition(tc.def, tc.schema); got != tc.want {
+				t.Errorf("normalizeDefinition(%q, %q) = %q, want %q", tc.def, tc.schema, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDiffSchemas(t *testing.T) {
+	search := schemaObject{"column", "users", "search"}
+	expected := map[schemaObject]string{
+		{"table", "users", ""}:                "BASE TABLE",
+		{"column", "users", "email"}:          "text NOT NULL",
+		search:                                "tsvector GENERATED ALWAYS AS (to_tsvector('simple'::regconfig, name)) STORED",
+		{"index", "users", "users_email_key"}: "CREATE UNIQUE INDEX users_email_key ON users USING btree (email)",
+	}
+	actual := map[schemaObject]string{
+		{"table", "users", ""}:          "BASE TABLE",
+		{"column", "users", "email"}:    "text NOT NULL",
+		search:                          "tsvector GENERATED ALWAYS AS (to_tsvector('english'::regconfig, name)) STORED",
+		{"column", "users", "nickname"}: "text",
+	}
+
+	want := []SchemaDifference{
+		{Kind: "column", Table: "users", Name: "nickname", Issue: "unexpected", Actual: "text"},
+		{Kind: "column", Table: "users", Name: "search", Issue: "changed", Expected: expected[search], Actual: actual[search]},
+		{Kind: "index", Table: "users", Name: "users_email_key", Issue: "missing", Expected: expected[schemaObject{"index", "users", "users_email_key"}]},
+	}
+	if got := diffSchemas(expected, actual); !reflect.DeepEqual(got, want) {
+		t.Errorf("diffSchemas =\n%+v\nwant\n%+v", got, want)
+	}
+	if got := diffSchemas(expected, expected); len(got) != 0 {
+		t.Errorf("diffSchemas of identical schemas = %+v, want none", got)
+	}
+}
+
+func TestDirtyErrorNamesPreviousMigration(t *testing.T) {
+	for _, tc := range []struct {
+		version uint
+		prev    int
+	}{
+		{8, 7},
+		{1, -1},
+	} {
+		var dirty *DirtyError
+		if err := dirtyError(tc.version); !errors.As(err, &dirty) || dirty.Version != tc.version || dirty.Prev != tc.prev {
+			t.Errorf("dirtyError(%d) = %v, want version %d and previous %d", tc.version, err, tc.version, tc.prev)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package _postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/rand/v2"
+	"practice4/practice-4/pkg/modules"
+	"time"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
+)
+
+// Querier runs statements on either the database or a transaction.
+type Querier interface {
+	sqlx.ExtContext
+	GetContext(ctx context.Context, dest any, query string, args ...any) error
+	SelectContext(ctx context.Context, dest any, query string, args ...any) error
+}
+
+type txKey struct{}
+
+func txFrom(ctx context.Context) (*sqlx.Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(*sqlx.Tx)
+	return tx, ok
+}
+
+// InTx reports whether ctx carries a transaction from TxManager.WithinTx.
+func InTx(ctx context.Context) bool {
+	_, ok := txFrom(ctx)
+	return ok
+}
+
+// Conn returns the transaction carried by ctx, if any, or the primary.
+func (d *Dialect) Conn(ctx context.Context) Querier {
+	if tx, ok := txFrom(ctx); ok {
+		return tx
+	}
+	return d.DB
+}
+
+// Tx is a transaction begun by BeginTx. Commit marks its context written
+// for TrackWrites. When it joined the transaction carried by the context,
+// Commit and Rollback leave both to its owner, as nothing is visible to
+// other connections until the owner commits.
+type Tx struct {
+	*sqlx.Tx
+	ctx    context.Context
+	joined bool
+}
+
+// BeginTx joins the transaction carried by ctx or begins a new one.
+func (d *Dialect) BeginTx(ctx context.Context) (*Tx, error) {
+	if tx, ok := txFrom(ctx); ok {
+		return &Tx{Tx: tx, ctx: ctx, joined: true}, nil
+	}
+	tx, err := d.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx, ctx: ctx}, nil
+}
+
+func (t *Tx) Commit() error {
+	if t.joined {
+		return nil
+	}
+	if err := t.Tx.Commit(); err != nil {
+		return err
+	}
+	MarkWritten(t.ctx)
+	return nil
+}
+
+func (t *Tx) Rollback() error {
+	if t.joined {
+		return nil
+	}
+	return t.Tx.Rollback()
+}
+
+var isolationLevels = map[string]sql.IsolationLevel{
+	"read-committed":  sql.LevelReadCommitted,
+	"repeatable-read": sql.LevelRepeatableRead,
+	"serializable":    sql.LevelSerializable,
+}
+
+const (
+	txRetryInitial = 10 * time.Millisecond
+	txRetryMax     = time.Second
+)
+
+// TxManager runs units of work spanning several repository calls in one
+// transaction, retrying them on serialization failures and deadlocks.
+type TxManager struct {
+	db         *Dialect
+	isolation  sql.IsolationLevel
+	maxRetries int
+}
+
+func NewTxManager(db *Dialect, cfg *modules.PostgreConfig) *TxManager {
+	return &TxManager{
+		db:         db,
+		isolation:  isolationLevels[cfg.TxIsolation],
+		maxRetries: cfg.TxMaxRetries,
+	}
+}
+
+// WithinTx calls fn with a context carrying a transaction that every
+// repository method uses, and commits it if fn succeeds. fn may run more
+// than once, so it must not have effects outside the database. Inside an
+// existing transaction fn simply joins it.
+func (m *TxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := txFrom(ctx); ok {
+		return fn(ctx)
+	}
+	delay := txRetryInitial
+	for attempt := 0; ; attempt++ {
+		err := m.run(ctx, fn)
+		if err == nil {
+			MarkWritten(ctx)
+			return nil
+		}
+		if attempt >= m.maxRetries || !IsRetryable(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay/2 + rand.N(delay/2+1)):
+		}
+		delay = min(delay*2, txRetryMax)
+	}
+}
+
+func (m *TxManager) run(ctx context.Context, fn func(ctx context.Context) error) error {
+	tx, err := m.db.DB.BeginTxx(ctx, &sql.TxOptions{Isolation: m.isolation})
+	if err != nil {
+		return WrapErr("WithinTx BeginTx", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return WrapErr("WithinTx commit", err)
+	}
+	return nil
+}
+
+// IsRetryable reports whether err is a serialization failure or deadlock,
+// after which the whole transaction may be retried.
+func IsRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == pgerrcode.SerializationFailure || pgErr.Code == pgerrcode.DeadlockDetected
+}
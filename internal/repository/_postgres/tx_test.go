@@ -0,0 +1,94 @@
+package _postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"practice4/practice-4/pkg/modules"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// txDriver is a database/sql driver whose connections only begin, commit and
+// roll back transactions.
+type txDriver struct{ commits int }
+
+func (d *txDriver) Connect(context.Context) (driver.Conn, error) { return txConn{d}, nil }
+func (d *txDriver) Driver() driver.Driver                        { return nil }
+
+type txConn struct{ d *txDriver }
+
+func (c txConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c txConn) Close() error                        { return nil }
+func (c txConn) Begin() (driver.Tx, error)           { return c, nil }
+func (c txConn) Commit() error                       { c.d.commits++; return nil }
+func (c txConn) Rollback() error                     { return nil }
+
+func TestCommitMarksWrittenOnlyWhenOwned(t *testing.T) {
+	drv := &txDriver{}
+	db := &Dialect{DB: sqlx.NewDb(sql.OpenDB(drv), "pgx")}
+	defer db.DB.Close()
+	txm := NewTxManager(db, &modules.PostgreConfig{})
+
+	write := func(ctx context.Context) error {
+		tx, err := db.BeginTx(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		return tx.Commit()
+	}
+
+	t.Run("own transaction", func(t *testing.T) {
+		ctx := TrackWrites(context.Background())
+		if err := write(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if !readsFromPrimary(ctx) {
+			t.Error("a committed write did not mark the context written")
+		}
+	})
+
+	t.Run("joined transaction", func(t *testing.T) {
+		ctx := TrackWrites(context.Background())
+		commits := drv.commits
+		err := txm.WithinTx(ctx, func(ctx context.Context) error {
+			if err := write(ctx); err != nil {
+				return err
+			}
+			if readsFromPrimary(ctx) {
+				t.Error("a write joined to an uncommitted transaction marked the context written")
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if drv.commits != commits+1 {
+			t.Errorf("%d commits, want only the owner's", drv.commits-commits)
+		}
+		if !readsFromPrimary(ctx) {
+			t.Error("the owning commit did not mark the context written")
+		}
+	})
+
+	t.Run("joined transaction rolled back", func(t *testing.T) {
+		ctx := TrackWrites(context.Background())
+		failed := errors.New("failed after the write")
+		err := txm.WithinTx(ctx, func(ctx context.Context) error {
+			if err := write(ctx); err != nil {
+				return err
+			}
+			return failed
+		})
+		if !errors.Is(err, failed) {
+			t.Fatalf("WithinTx = %v, want %v", err, failed)
+		}
+		if readsFromPrimary(ctx) {
+			t.Error("a rolled back write marked the context written")
+		}
+	})
+}
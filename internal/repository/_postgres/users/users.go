@@ -3,128 +3,362 @@ package users
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"log"
+	"strings"
 	"time"
 
 	"practice4/practice-4/internal/repository/_postgres"
 	"practice4/practice-4/pkg/apperrors"
 	"practice4/practice-4/pkg/modules"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
 )
 
 type Repository struct {
 	db            *_postgres.Dialect
 	executionTime time.Duration
+	opTimeouts    map[string]time.Duration
 }
 
-func NewUserRepository(dv *_postgres.Dialect) *Repository {
+func NewUserRepository(dv *_postgres.Dialect, cfg *modules.PostgreConfig) *Repository {
+	executionTime := cfg.ExecTimeout
+	if executionTime <= 0 {
+		executionTime = 5 * time.Second
+	}
 	return &Repository{
 		db:            dv,
-		executionTime: 5 * time.Second,
+		executionTime: executionTime,
+		opTimeouts:    cfg.OpTimeouts,
+	}
+}
+
+func (r *Repository) withTimeout(ctx context.Context, op string) (context.Context, context.CancelFunc) {
+	timeout := r.executionTime
+	if d, ok := r.opTimeouts[op]; ok {
+		timeout = d
 	}
+	return context.WithTimeout(ctx, timeout)
 }
 
-func (r *Repository) GetAll(ctx context.Context, limit, offset int64) ([]modules.User, error) {
+const userFilterClause = `deleted_at IS NULL
+	AND ($1 = '' OR name ILIKE $1) AND ($2 = '' OR email ILIKE $2) AND id > $3`
+
+// likePattern turns a substring into an ILIKE pattern, escaping wildcards.
+func likePattern(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s) + "%"
+}
+
+func (r *Repository) GetAll(ctx context.Context, filter modules.UserFilter, limit, offset int64) ([]modules.User, error) {
+	ctx, cancel := r.withTimeout(ctx, "GetAll")
+	defer cancel()
+
 	var users []modules.User
-	err := r.db.DB.SelectContext(ctx, &users,
-		"SELECT id, name, email, created_at FROM users WHERE deleted_at IS NULL ORDER BY id LIMIT $1 OFFSET $2",
-		limit, offset)
+	err := r.db.Read(ctx, func(db _postgres.Querier) error {
+		users = nil
+		return db.SelectContext(ctx, &users,
+			"SELECT id, name, email, created_at FROM users WHERE "+userFilterClause+" ORDER BY id LIMIT $4 OFFSET $5",
+			likePattern(filter.Name), likePattern(filter.Email), filter.AfterID, limit, offset)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("GetAll: %w", err)
+		return nil, _postgres.WrapErr("GetAll", err)
 	}
 	return users, nil
 }
 
-func (r *Repository) CountUsers(ctx context.Context) (int64, error) {
+func (r *Repository) CountUsers(ctx context.Context, filter modules.UserFilter) (int64, error) {
+	ctx, cancel := r.withTimeout(ctx, "CountUsers")
+	defer cancel()
+
 	var count int64
-	err := r.db.DB.GetContext(ctx, &count,
-		"SELECT COUNT(*) FROM users WHERE deleted_at IS NULL")
+	err := r.db.Read(ctx, func(db _postgres.Querier) error {
+		return db.GetContext(ctx, &count,
+			"SELECT COUNT(*) FROM users WHERE "+userFilterClause,
+			likePattern(filter.Name), likePattern(filter.Email), filter.AfterID)
+	})
 	if err != nil {
-		return 0, fmt.Errorf("CountUsers: %w", err)
+		return 0, _postgres.WrapErr("CountUsers", err)
 	}
 	return count, nil
 }
 
+// Search returns up to limit users matching query, best first. A user
+// matches on full-text search over name and email, on a substring of
+// either, or on a trigram match that tolerates misspellings.
+func (r *Repository) Search(ctx context.Context, query string, limit int64) ([]modules.UserSearchResult, error) {
+	ctx, cancel := r.withTimeout(ctx, "Search")
+	defer cancel()
+
+	var rows []struct {
+		modules.User
+		Rank float64 `db:"rank"`
+	}
+	err := r.db.Read(ctx, func(db _postgres.Querier) error {
+		rows = nil
+		return db.SelectContext(ctx, &rows, `SELECT id, name, email, created_at,
+				ts_rank(search, q) + word_similarity($1, name) + word_similarity($1, email) AS rank
+			FROM users, websearch_to_tsquery('simple', $1) q
+			WHERE deleted_at IS NULL
+				AND (search @@ q OR name ILIKE $2 OR email ILIKE $2 OR $1 <% name OR $1 <% email)
+			ORDER BY rank DESC, id LIMIT $3`, query, likePattern(query), limit)
+	})
+	if err != nil {
+		return nil, _postgres.WrapErr("Search", err)
+	}
+	results := make([]modules.UserSearchResult, len(rows))
+	for i, row := range rows {
+		results[i] = modules.UserSearchResult{User: row.User, Rank: row.Rank}
+	}
+	return results, nil
+}
+
+// EstimateUsers returns the planner's estimate of how many users match
+// filter: the table's row estimate when unfiltered, otherwise the row count
+// EXPLAIN expects. It is -1 when the table has never been analyzed.
+func (r *Repository) EstimateUsers(ctx context.Context, filter modules.UserFilter) (int64, error) {
+	ctx, cancel := r.withTimeout(ctx, "EstimateUsers")
+	defer cancel()
+
+	var estimate float64
+	err := r.db.Read(ctx, func(db _postgres.Querier) error {
+		if filter == (modules.UserFilter{}) {
+			return db.GetContext(ctx, &estimate, "SELECT reltuples FROM pg_class WHERE oid = 'users'::regclass")
+		}
+		var plan []byte
+		err := db.GetContext(ctx, &plan,
+			"EXPLAIN (FORMAT JSON) SELECT 1 FROM users WHERE "+userFilterClause,
+			likePattern(filter.Name), likePattern(filter.Email), filter.AfterID)
+		if err != nil {
+			return err
+		}
+		var explained []struct {
+			Plan struct {
+				Rows float64 `json:"Plan Rows"`
+			} `json:"Plan"`
+		}
+		if err := json.Unmarshal(plan, &explained); err != nil || len(explained) == 0 {
+			return fmt.Errorf("unexpected plan %q: %v", plan, err)
+		}
+		estimate = explained[0].Plan.Rows
+		return nil
+	})
+	if err != nil {
+		return 0, _postgres.WrapErr("EstimateUsers", err)
+	}
+	return int64(estimate), nil
+}
+
 func (r *Repository) GetByID(ctx context.Context, id int64) (*modules.User, error) {
+	ctx, cancel := r.withTimeout(ctx, "GetByID")
+	defer cancel()
+
 	user := &modules.User{}
-	err := r.db.DB.GetContext(ctx, user,
-		"SELECT id, name, email, created_at FROM users WHERE id = $1 AND deleted_at IS NULL", id)
+	err := r.db.Read(ctx, func(db _postgres.Querier) error {
+		return db.GetContext(ctx, user,
+			"SELECT id, name, email, created_at FROM users WHERE id = $1 AND deleted_at IS NULL", id)
+	})
 	if err == sql.ErrNoRows {
 		return nil, apperrors.ErrNotFound
 	}
 	if err != nil {
-		return nil, fmt.Errorf("GetByID: %w", err)
+		return nil, _postgres.WrapErr("GetByID", err)
 	}
 	return user, nil
 }
 
-func (r *Repository) Create(ctx context.Context, user *modules.User) (int64, error) {
-	var id int64
-	err := r.db.DB.QueryRowContext(ctx,
-		"INSERT INTO users (name, email, created_at) VALUES ($1, $2, $3) RETURNING id",
-		user.Name, user.Email, time.Now()).Scan(&id)
+const userColumns = "id, name, email, created_at, deleted_at"
+
+// inTx runs fn in a transaction, joining the one carried by ctx if any, and
+// records the event it returns in the outbox before committing, so the
+// change and its event land together. Once the transaction that owns the
+// change commits, later reads with a TrackWrites context go to the primary.
+func (r *Repository) inTx(ctx context.Context, op string, fn func(tx *sqlx.Tx) (eventType string, user *modules.User, err error)) error {
+	ctx, cancel := r.withTimeout(ctx, op)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx)
 	if err != nil {
-		return 0, fmt.Errorf("Create: %w", err)
+		return _postgres.WrapErr(op+" BeginTx", err)
 	}
-	return id, nil
-}
+	defer tx.Rollback()
 
-func (r *Repository) Update(ctx context.Context, user *modules.User) error {
-	result, err := r.db.DB.ExecContext(ctx,
-		"UPDATE users SET name = $1, email = $2 WHERE id = $3 AND deleted_at IS NULL",
-		user.Name, user.Email, user.ID)
+	eventType, user, err := fn(tx.Tx)
 	if err != nil {
-		return fmt.Errorf("Update: %w", err)
+		return err
 	}
-	rowsAffected, err := result.RowsAffected()
+	payload, err := json.Marshal(user)
 	if err != nil {
-		return fmt.Errorf("Update RowsAffected: %w", err)
+		return fmt.Errorf("%s marshal event: %w", op, err)
 	}
-	if rowsAffected == 0 {
-		return apperrors.ErrNotFound
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO outbox (event_type, aggregate_id, payload) VALUES ($1, $2, $3)",
+		eventType, user.ID, string(payload))
+	if err != nil {
+		return _postgres.WrapErr(op+" insert event", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return _postgres.WrapErr(op+" commit", err)
 	}
 	return nil
 }
 
+func (r *Repository) Create(ctx context.Context, user *modules.User) (int64, error) {
+	return r.create(ctx, "Create", user)
+}
+
+func (r *Repository) create(ctx context.Context, op string, user *modules.User) (int64, error) {
+	var id int64
+	err := r.inTx(ctx, op, func(tx *sqlx.Tx) (string, *modules.User, error) {
+		created := &modules.User{}
+		err := tx.GetContext(ctx, created,
+			"INSERT INTO users (name, email, created_at) VALUES ($1, $2, $3) RETURNING "+userColumns,
+			user.Name, user.Email, time.Now())
+		if err != nil {
+			return "", nil, _postgres.WrapErr(op, err)
+		}
+		id = created.ID
+		return modules.EventUserCreated, created, nil
+	})
+	return id, err
+}
+
+func (r *Repository) Update(ctx context.Context, user *modules.User) error {
+	return r.inTx(ctx, "Update", func(tx *sqlx.Tx) (string, *modules.User, error) {
+		updated, err := updateReturning(ctx, tx, "Update",
+			"UPDATE users SET name = $1, email = $2 WHERE id = $3 AND deleted_at IS NULL RETURNING "+userColumns,
+			user.Name, user.Email, user.ID)
+		return modules.EventUserUpdated, updated, err
+	})
+}
+
 func (r *Repository) Delete(ctx context.Context, id int64) error {
-	result, err := r.db.DB.ExecContext(ctx,
-		"UPDATE users SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL", id)
-	if err != nil {
-		return fmt.Errorf("Delete: %w", err)
+	return r.inTx(ctx, "Delete", func(tx *sqlx.Tx) (string, *modules.User, error) {
+		deleted, err := updateReturning(ctx, tx, "Delete",
+			"UPDATE users SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL RETURNING "+userColumns, id)
+		return modules.EventUserDeleted, deleted, err
+	})
+}
+
+func (r *Repository) Restore(ctx context.Context, id int64) error {
+	return r.inTx(ctx, "Restore", func(tx *sqlx.Tx) (string, *modules.User, error) {
+		restored, err := updateReturning(ctx, tx, "Restore",
+			"UPDATE users SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL RETURNING "+userColumns, id)
+		return modules.EventUserRestored, restored, err
+	})
+}
+
+func updateReturning(ctx context.Context, tx *sqlx.Tx, op, query string, args ...any) (*modules.User, error) {
+	user := &modules.User{}
+	err := tx.GetContext(ctx, user, query, args...)
+	if err == sql.ErrNoRows {
+		return nil, apperrors.ErrNotFound
 	}
-	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("Delete RowsAffected: %w", err)
+		return nil, _postgres.WrapErr(op, err)
 	}
-	if rowsAffected == 0 {
-		return apperrors.ErrNotFound
+	return user, nil
+}
+
+func (r *Repository) ListAuditLogs(ctx context.Context, filter modules.AuditLogFilter) ([]modules.AuditLog, error) {
+	ctx, cancel := r.withTimeout(ctx, "ListAuditLogs")
+	defer cancel()
+
+	query := `SELECT id, user_id, action, created_at, COALESCE(seq, 0) AS seq FROM audit_logs
+		WHERE ($1 = 0 OR user_id = $1) AND id > $2 ORDER BY id LIMIT $3`
+	after := filter.AfterID
+	switch {
+	case filter.AfterSeq > 0:
+		// Entries still committing have no seq yet; they follow later.
+		query = `SELECT id, user_id, action, created_at, seq FROM audit_logs
+			WHERE ($1 = 0 OR user_id = $1) AND seq > $2 ORDER BY seq LIMIT $3`
+		after = filter.AfterSeq
+	case filter.Tail && filter.AfterID == 0:
+		query = `SELECT * FROM (SELECT id, user_id, action, created_at, COALESCE(seq, 0) AS seq FROM audit_logs
+			WHERE ($1 = 0 OR user_id = $1) AND id > $2 ORDER BY id DESC LIMIT $3) t ORDER BY id`
 	}
-	return nil
+
+	logs := []modules.AuditLog{}
+	if err := r.db.Conn(ctx).SelectContext(ctx, &logs, query, filter.UserID, after, filter.Limit); err != nil {
+		return nil, _postgres.WrapErr("ListAuditLogs", err)
+	}
+	return logs, nil
 }
 
-func (r *Repository) CreateUserWithAudit(ctx context.Context, user *modules.User) (int64, error) {
-	tx, err := r.db.DB.BeginTxx(ctx, nil)
+// ListAuditLogsByUsers returns up to perUser of the most recent entries for
+// each of userIDs in one query, ordered by user and ID.
+func (r *Repository) ListAuditLogsByUsers(ctx context.Context, userIDs []int64, perUser int64) ([]modules.AuditLog, error) {
+	ctx, cancel := r.withTimeout(ctx, "ListAuditLogsByUsers")
+	defer cancel()
+
+	logs := []modules.AuditLog{}
+	err := r.db.Conn(ctx).SelectContext(ctx, &logs, `SELECT id, user_id, action, created_at FROM (
+			SELECT id, user_id, action, created_at,
+				row_number() OVER (PARTITION BY user_id ORDER BY id DESC) AS rn
+			FROM audit_logs WHERE user_id = ANY($1)) t
+		WHERE rn <= $2 ORDER BY user_id, id`, userIDs, perUser)
 	if err != nil {
-		return 0, fmt.Errorf("CreateUserWithAudit BeginTx: %w", err)
+		return nil, _postgres.WrapErr("ListAuditLogsByUsers", err)
 	}
-	defer tx.Rollback()
+	return logs, nil
+}
 
-	var id int64
-	err = tx.QueryRowContext(ctx,
-		"INSERT INTO users (name, email, created_at) VALUES ($1, $2, $3) RETURNING id",
-		user.Name, user.Email, time.Now()).Scan(&id)
+// Truncate removes all users with their audit logs, unpublished events and
+// webhook deliveries. IDs restart, so anything left would name the wrong user.
+func (r *Repository) Truncate(ctx context.Context) error {
+	ctx, cancel := r.withTimeout(ctx, "Truncate")
+	defer cancel()
+
+	_, err := r.db.Conn(ctx).ExecContext(ctx, "TRUNCATE users, audit_logs, outbox, webhook_deliveries RESTART IDENTITY")
 	if err != nil {
-		return 0, fmt.Errorf("CreateUserWithAudit insert user: %w", err)
+		return _postgres.WrapErr("Truncate", err)
 	}
+	return nil
+}
 
-	_, err = tx.ExecContext(ctx,
-		"INSERT INTO audit_logs (user_id, action, created_at) VALUES ($1, $2, $3)",
-		id, "create", time.Now())
+// CopyUsers inserts users in bulk through COPY, bypassing per-row round trips.
+// COPY runs on its own connection, outside any transaction carried by ctx.
+func (r *Repository) CopyUsers(ctx context.Context, users []modules.User) (int64, error) {
+	ctx, cancel := r.withTimeout(ctx, "CopyUsers")
+	defer cancel()
+
+	now := time.Now()
+	rows := make([][]any, len(users))
+	for i, u := range users {
+		createdAt := u.CreatedAt
+		if createdAt.IsZero() {
+			createdAt = now
+		}
+		rows[i] = []any{u.Name, u.Email, createdAt}
+	}
+	n, err := r.db.CopyFrom(ctx, "users", []string{"name", "email", "created_at"}, rows)
 	if err != nil {
-		return 0, fmt.Errorf("CreateUserWithAudit insert audit: %w", err)
+		return 0, _postgres.WrapErr("CopyUsers", err)
 	}
+	return n, nil
+}
 
-	if err = tx.Commit(); err != nil {
-		return 0, fmt.Errorf("CreateUserWithAudit commit: %w", err)
-	}
-	return id, nil
+// CreateUserWithAudit predates the users_audit trigger, which now writes the
+// audit log entry for every change; it is Create under its own timeout.
+func (r *Repository) CreateUserWithAudit(ctx context.Context, user *modules.User) (int64, error) {
+	return r.create(ctx, "CreateUserWithAudit", user)
+}
+
+// ListenChanges calls handle with the audit log entry of every user change,
+// as announced in commit order by the audit_logs_sequence trigger, until ctx
+// is cancelled or the connection fails.
+func (r *Repository) ListenChanges(ctx context.Context, handle func(modules.AuditLog)) error {
+	return r.db.Listen(ctx, "user_events", func(n *pgconn.Notification) {
+		var entry modules.AuditLog
+		if err := json.Unmarshal([]byte(n.Payload), &entry); err != nil {
+			log.Printf("user_events: bad payload %q: %v", n.Payload, err)
+			return
+		}
+		handle(entry)
+	})
 }
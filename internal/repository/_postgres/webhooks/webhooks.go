@@ -0,0 +1,271 @@
+package webhooks
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"practice4/practice-4/internal/repository/_postgres"
+	"practice4/practice-4/pkg/apperrors"
+	"practice4/practice-4/pkg/modules"
+)
+
+type Repository struct {
+	db            *_postgres.Dialect
+	executionTime time.Duration
+}
+
+func NewWebhookRepository(db *_postgres.Dialect, cfg *modules.PostgreConfig) *Repository {
+	executionTime := cfg.ExecTimeout
+	if executionTime <= 0 {
+		executionTime = 5 * time.Second
+	}
+	return &Repository{db: db, executionTime: executionTime}
+}
+
+const subscriptionColumns = "id, url, array_to_string(event_types, ',') AS event_types, secret, created_at"
+
+type subscriptionRow struct {
+	ID         int64     `db:"id"`
+	URL        string    `db:"url"`
+	EventTypes string    `db:"event_types"`
+	Secret     string    `db:"secret"`
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+func (row subscriptionRow) subscription() modules.WebhookSubscription {
+	return modules.WebhookSubscription{
+		ID:         row.ID,
+		URL:        row.URL,
+		EventTypes: strings.Split(row.EventTypes, ","),
+		Secret:     row.Secret,
+		CreatedAt:  row.CreatedAt,
+	}
+}
+
+const deliveryColumns = `id, subscription_id, event_id, event_type, body::text AS body, status, attempts,
+	next_attempt_at, last_status_code, last_error, replay_of, created_at, delivered_at`
+
+type deliveryRow struct {
+	ID             int64      `db:"id"`
+	SubscriptionID int64      `db:"subscription_id"`
+	EventID        int64      `db:"event_id"`
+	EventType      string     `db:"event_type"`
+	Body           []byte     `db:"body"`
+	Status         string     `db:"status"`
+	Attempts       int        `db:"attempts"`
+	NextAttemptAt  time.Time  `db:"next_attempt_at"`
+	LastStatusCode int        `db:"last_status_code"`
+	LastError      string     `db:"last_error"`
+	ReplayOf       *int64     `db:"replay_of"`
+	CreatedAt      time.Time  `db:"created_at"`
+	DeliveredAt    *time.Time `db:"delivered_at"`
+}
+
+func (row deliveryRow) delivery() modules.WebhookDelivery {
+	return modules.WebhookDelivery{
+		ID:             row.ID,
+		SubscriptionID: row.SubscriptionID,
+		EventID:        row.EventID,
+		EventType:      row.EventType,
+		Body:           row.Body,
+		Status:         row.Status,
+		Attempts:       row.Attempts,
+		NextAttemptAt:  row.NextAttemptAt,
+		LastStatusCode: row.LastStatusCode,
+		LastError:      row.LastError,
+		ReplayOf:       row.ReplayOf,
+		CreatedAt:      row.CreatedAt,
+		DeliveredAt:    row.DeliveredAt,
+	}
+}
+
+func (r *Repository) CreateSubscription(ctx context.Context, sub *modules.WebhookSubscription) (*modules.WebhookSubscription, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.executionTime)
+	defer cancel()
+
+	var row subscriptionRow
+	err := r.db.Conn(ctx).GetContext(ctx, &row,
+		"INSERT INTO webhook_subscriptions (url, event_types, secret) VALUES ($1, $2, $3) RETURNING "+subscriptionColumns,
+		sub.URL, sub.EventTypes, sub.Secret)
+	if err != nil {
+		return nil, _postgres.WrapErr("CreateSubscription", err)
+	}
+	created := row.subscription()
+	return &created, nil
+}
+
+func (r *Repository) ListSubscriptions(ctx context.Context) ([]modules.WebhookSubscription, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.executionTime)
+	defer cancel()
+
+	var rows []subscriptionRow
+	if err := r.db.Conn(ctx).SelectContext(ctx, &rows, "SELECT "+subscriptionColumns+" FROM webhook_subscriptions ORDER BY id"); err != nil {
+		return nil, _postgres.WrapErr("ListSubscriptions", err)
+	}
+	subs := make([]modules.WebhookSubscription, len(rows))
+	for i, row := range rows {
+		subs[i] = row.subscription()
+	}
+	return subs, nil
+}
+
+func (r *Repository) GetSubscription(ctx context.Context, id int64) (*modules.WebhookSubscription, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.executionTime)
+	defer cancel()
+
+	var row subscriptionRow
+	err := r.db.Conn(ctx).GetContext(ctx, &row, "SELECT "+subscriptionColumns+" FROM webhook_subscriptions WHERE id = $1", id)
+	if err == sql.ErrNoRows {
+		return nil, apperrors.ErrNotFound
+	}
+	if err != nil {
+		return nil, _postgres.WrapErr("GetSubscription", err)
+	}
+	sub := row.subscription()
+	return &sub, nil
+}
+
+func (r *Repository) DeleteSubscription(ctx context.Context, id int64) error {
+	ctx, cancel := context.WithTimeout(ctx, r.executionTime)
+	defer cancel()
+
+	result, err := r.db.Conn(ctx).ExecContext(ctx, "DELETE FROM webhook_subscriptions WHERE id = $1", id)
+	if err != nil {
+		return _postgres.WrapErr("DeleteSubscription", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return _postgres.WrapErr("DeleteSubscription RowsAffected", err)
+	}
+	if rowsAffected == 0 {
+		return apperrors.ErrNotFound
+	}
+	return nil
+}
+
+// EnqueueDeliveries queues body for every subscription to the event's type.
+// Enqueuing the same event twice is a no-op, so the outbox relay may retry.
+func (r *Repository) EnqueueDeliveries(ctx context.Context, event modules.Event, body []byte) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.executionTime)
+	defer cancel()
+
+	result, err := r.db.Conn(ctx).ExecContext(ctx, `INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, body)
+		SELECT id, $1, $2, $3 FROM webhook_subscriptions WHERE $2 = ANY(event_types)
+		ON CONFLICT (subscription_id, event_id) WHERE replay_of IS NULL DO NOTHING`,
+		event.ID, event.Type, string(body))
+	if err != nil {
+		return 0, _postgres.WrapErr("EnqueueDeliveries", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, _postgres.WrapErr("EnqueueDeliveries RowsAffected", err)
+	}
+	return n, nil
+}
+
+func (r *Repository) ListDeliveries(ctx context.Context, subscriptionID int64, filter modules.DeliveryFilter) ([]modules.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.executionTime)
+	defer cancel()
+
+	var rows []deliveryRow
+	err := r.db.Conn(ctx).SelectContext(ctx, &rows, "SELECT "+deliveryColumns+` FROM webhook_deliveries
+		WHERE subscription_id = $1 AND ($2 = '' OR status = $2) AND ($3 = 0 OR id < $3)
+		ORDER BY id DESC LIMIT $4`, subscriptionID, filter.Status, filter.BeforeID, filter.Limit)
+	if err != nil {
+		return nil, _postgres.WrapErr("ListDeliveries", err)
+	}
+	deliveries := make([]modules.WebhookDelivery, len(rows))
+	for i, row := range rows {
+		deliveries[i] = row.delivery()
+	}
+	return deliveries, nil
+}
+
+func (r *Repository) GetDelivery(ctx context.Context, subscriptionID, id int64) (*modules.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.executionTime)
+	defer cancel()
+
+	var row deliveryRow
+	err := r.db.Conn(ctx).GetContext(ctx, &row, "SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE id = $1 AND subscription_id = $2",
+		id, subscriptionID)
+	if err == sql.ErrNoRows {
+		return nil, apperrors.ErrNotFound
+	}
+	if err != nil {
+		return nil, _postgres.WrapErr("GetDelivery", err)
+	}
+	d := row.delivery()
+	return &d, nil
+}
+
+// ReplayDelivery queues a fresh copy of a delivery, whatever its state, and
+// returns it. The original keeps its history.
+func (r *Repository) ReplayDelivery(ctx context.Context, subscriptionID, id int64) (*modules.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.executionTime)
+	defer cancel()
+
+	var row deliveryRow
+	err := r.db.Conn(ctx).GetContext(ctx, &row, `INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, body, replay_of)
+		SELECT subscription_id, event_id, event_type, body, id FROM webhook_deliveries WHERE id = $1 AND subscription_id = $2
+		RETURNING `+deliveryColumns, id, subscriptionID)
+	if err == sql.ErrNoRows {
+		return nil, apperrors.ErrNotFound
+	}
+	if err != nil {
+		return nil, _postgres.WrapErr("ReplayDelivery", err)
+	}
+	d := row.delivery()
+	return &d, nil
+}
+
+// ClaimDue leases up to limit due pending deliveries by pushing their next
+// attempt lease into the future, so concurrent dispatchers skip them while
+// they are sent, and a dispatcher that dies lets them fall due again. No
+// transaction or lock outlives the statement.
+func (r *Repository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]modules.DueDelivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.executionTime)
+	defer cancel()
+
+	var rows []struct {
+		deliveryRow
+		URL    string `db:"url"`
+		Secret string `db:"secret"`
+	}
+	err := r.db.Conn(ctx).SelectContext(ctx, &rows, `WITH due AS (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at, id LIMIT $1 FOR UPDATE SKIP LOCKED)
+		UPDATE webhook_deliveries d SET next_attempt_at = NOW() + make_interval(secs => $2)
+		FROM due, webhook_subscriptions s
+		WHERE d.id = due.id AND s.id = d.subscription_id
+		RETURNING d.id, d.subscription_id, d.event_id, d.event_type, d.body::text AS body, d.status, d.attempts,
+			d.next_attempt_at, d.last_status_code, d.last_error, d.replay_of, d.created_at, d.delivered_at,
+			s.url, s.secret`, limit, lease.Seconds())
+	if err != nil {
+		return nil, _postgres.WrapErr("ClaimDue", err)
+	}
+	due := make([]modules.DueDelivery, len(rows))
+	for i, row := range rows {
+		due[i] = modules.DueDelivery{WebhookDelivery: row.delivery(), URL: row.URL, Secret: row.Secret}
+	}
+	return due, nil
+}
+
+// RecordAttempt saves the outcome of sending a claimed delivery: its status,
+// attempts, next attempt and last result.
+func (r *Repository) RecordAttempt(ctx context.Context, d *modules.WebhookDelivery) error {
+	ctx, cancel := context.WithTimeout(ctx, r.executionTime)
+	defer cancel()
+
+	// Times are computed by the database so they agree with NOW() in ClaimDue.
+	_, err := r.db.Conn(ctx).ExecContext(ctx, `UPDATE webhook_deliveries SET status = $1, attempts = $2,
+		next_attempt_at = NOW() + make_interval(secs => $3), last_status_code = $4, last_error = $5,
+		delivered_at = CASE WHEN $1 = 'succeeded' THEN NOW() END WHERE id = $6 AND status = 'pending'`,
+		d.Status, d.Attempts, max(time.Until(d.NextAttemptAt), 0).Seconds(), d.LastStatusCode, d.LastError, d.ID)
+	if err != nil {
+		return _postgres.WrapErr("RecordAttempt", err)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func get(t *testing.T, c *LRU, key string) (string, bool) {
+	t.Helper()
+	v, ok, err := c.Get(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(v), ok
+}
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU(2)
+	c.Set(ctx, "a", []byte("1"), 0)
+	c.Set(ctx, "b", []byte("2"), 0)
+	get(t, c, "a") // b is now the least recently used
+	c.Set(ctx, "c", []byte("3"), 0)
+
+	if _, ok := get(t, c, "b"); ok {
+		t.Error("b was not evicted")
+	}
+	for key, want := range map[string]string{"a": "1", "c": "3"} {
+		if v, ok := get(t, c, key); !ok || v != want {
+			t.Errorf("Get(%q) = %q, %v; want %q", key, v, ok, want)
+		}
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len = %d, want 2", c.Len())
+	}
+}
+
+func TestLRUReplacesExistingKey(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU(2)
+	c.Set(ctx, "a", []byte("1"), 0)
+	c.Set(ctx, "a", []byte("2"), 0)
+	if v, _ := get(t, c, "a"); v != "2" || c.Len() != 1 {
+		t.Errorf("Get(a) = %q with %d entries, want 2 with 1", v, c.Len())
+	}
+}
+
+func TestLRUExpiresEntries(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU(10)
+	c.Set(ctx, "short", []byte("1"), 10*time.Millisecond)
+	c.Set(ctx, "forever", []byte("2"), 0)
+	if _, ok := get(t, c, "short"); !ok {
+		t.Fatal("entry expired immediately")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := get(t, c, "short"); ok {
+		t.Error("entry outlived its ttl")
+	}
+	if _, ok := get(t, c, "forever"); !ok {
+		t.Error("entry without ttl expired")
+	}
+	if c.Len() != 1 {
+		t.Errorf("Len = %d, want the expired entry removed", c.Len())
+	}
+}
@@ -0,0 +1,199 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"practice4/practice-4/internal/repository"
+	"practice4/practice-4/internal/repository/_postgres"
+	"practice4/practice-4/pkg/modules"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// generationKey holds a token that is part of every other key. Replacing it
+// on each write invalidates all cached reads at once, including reads that
+// were in flight when the write happened.
+const generationKey = "users:generation"
+
+// Stats counts cache lookups since start.
+type Stats struct {
+	Hits          uint64 `json:"hits"`
+	Misses        uint64 `json:"misses"`
+	Invalidations uint64 `json:"invalidations"`
+	Errors        uint64 `json:"errors"`
+}
+
+// UserRepository is a read-through cache in front of another
+// repository.UserRepository. GetByID, GetAll and CountUsers are cached for
+// ttl; concurrent misses for the same key share one load, and every write
+// through it invalidates the cache. Reads inside a transaction bypass it.
+type UserRepository struct {
+	repository.UserRepository
+	cache Cache
+	ttl   time.Duration
+	group singleflight.Group
+
+	hits, misses, invalidations, errors atomic.Uint64
+}
+
+func NewUserRepository(next repository.UserRepository, cache Cache, ttl time.Duration) *UserRepository {
+	return &UserRepository{UserRepository: next, cache: cache, ttl: ttl}
+}
+
+func (r *UserRepository) Stats() Stats {
+	return Stats{
+		Hits:          r.hits.Load(),
+		Misses:        r.misses.Load(),
+		Invalidations: r.invalidations.Load(),
+		Errors:        r.errors.Load(),
+	}
+}
+
+func (r *UserRepository) GetByID(ctx context.Context, id int64) (*modules.User, error) {
+	var user modules.User
+	err := r.read(ctx, "id:"+strconv.FormatInt(id, 10), &user, func(ctx context.Context) (any, error) {
+		return r.UserRepository.GetByID(ctx, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *UserRepository) GetAll(ctx context.Context, filter modules.UserFilter, limit, offset int64) ([]modules.User, error) {
+	var users []modules.User
+	key := fmt.Sprintf("all:%q:%q:%d:%d:%d", filter.Name, filter.Email, filter.AfterID, limit, offset)
+	err := r.read(ctx, key, &users, func(ctx context.Context) (any, error) {
+		return r.UserRepository.GetAll(ctx, filter, limit, offset)
+	})
+	return users, err
+}
+
+func (r *UserRepository) CountUsers(ctx context.Context, filter modules.UserFilter) (int64, error) {
+	var count int64
+	key := fmt.Sprintf("count:%q:%q:%d", filter.Name, filter.Email, filter.AfterID)
+	err := r.read(ctx, key, &count, func(ctx context.Context) (any, error) {
+		return r.UserRepository.CountUsers(ctx, filter)
+	})
+	return count, err
+}
+
+func (r *UserRepository) Create(ctx context.Context, user *modules.User) (int64, error) {
+	defer r.Invalidate(ctx)
+	return r.UserRepository.Create(ctx, user)
+}
+
+func (r *UserRepository) CreateUserWithAudit(ctx context.Context, user *modules.User) (int64, error) {
+	defer r.Invalidate(ctx)
+	return r.UserRepository.CreateUserWithAudit(ctx, user)
+}
+
+func (r *UserRepository) Update(ctx context.Context, user *modules.User) error {
+	defer r.Invalidate(ctx)
+	return r.UserRepository.Update(ctx, user)
+}
+
+func (r *UserRepository) Delete(ctx context.Context, id int64) error {
+	defer r.Invalidate(ctx)
+	return r.UserRepository.Delete(ctx, id)
+}
+
+func (r *UserRepository) Restore(ctx context.Context, id int64) error {
+	defer r.Invalidate(ctx)
+	return r.UserRepository.Restore(ctx, id)
+}
+
+// Invalidate drops every cached read, e.g. when another instance reports a
+// change.
+func (r *UserRepository) Invalidate(ctx context.Context) {
+	r.invalidations.Add(1)
+	if _, err := r.newGeneration(ctx); err != nil {
+		r.fail("invalidate", err)
+	}
+}
+
+// read decodes the cached value for key into dst, loading and caching it on
+// a miss. Cache errors fall back to the underlying repository.
+func (r *UserRepository) read(ctx context.Context, key string, dst any, load func(context.Context) (any, error)) error {
+	// A transaction may see its own uncommitted writes, which must neither
+	// be cached nor shared with other callers.
+	if _postgres.InTx(ctx) {
+		return r.loadInto(ctx, dst, load)
+	}
+	gen, err := r.generation(ctx)
+	if err != nil {
+		r.fail("generation", err)
+		return r.loadInto(ctx, dst, load)
+	}
+	key = "users:" + gen + ":" + key
+
+	if data, ok, err := r.cache.Get(ctx, key); err != nil {
+		r.fail("get", err)
+	} else if ok && json.Unmarshal(data, dst) == nil {
+		r.hits.Add(1)
+		return nil
+	}
+	r.misses.Add(1)
+
+	// The load is shared, so it must not be cut short by whichever caller
+	// happened to start it going away. It reads from the primary: a lagging
+	// replica would otherwise refill the cache with rows from before the
+	// write that just invalidated it, and keep serving them for the ttl.
+	shared := _postgres.WithPrimary(context.WithoutCancel(ctx))
+	v, err, _ := r.group.Do(key, func() (any, error) {
+		data, err := r.loadJSON(shared, load)
+		if err != nil {
+			return nil, err
+		}
+		if err := r.cache.Set(shared, key, data, r.ttl); err != nil {
+			r.fail("set", err)
+		}
+		return data, nil
+	})
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(v.([]byte), dst)
+}
+
+func (r *UserRepository) loadJSON(ctx context.Context, load func(context.Context) (any, error)) ([]byte, error) {
+	v, err := load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+func (r *UserRepository) loadInto(ctx context.Context, dst any, load func(context.Context) (any, error)) error {
+	data, err := r.loadJSON(ctx, load)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dst)
+}
+
+func (r *UserRepository) generation(ctx context.Context) (string, error) {
+	data, ok, err := r.cache.Get(ctx, generationKey)
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		return string(data), nil
+	}
+	return r.newGeneration(ctx)
+}
+
+func (r *UserRepository) newGeneration(ctx context.Context) (string, error) {
+	gen := strconv.FormatInt(time.Now().UnixNano(), 36)
+	return gen, r.cache.Set(ctx, generationKey, []byte(gen), 0)
+}
+
+func (r *UserRepository) fail(op string, err error) {
+	r.errors.Add(1)
+	log.Printf("user cache %s: %v", op, err)
+}
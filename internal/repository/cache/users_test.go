@@ -0,0 +1,132 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"practice4/practice-4/internal/repository"
+	"practice4/practice-4/pkg/modules"
+)
+
+// countingRepo serves one user and counts the loads that reach it. When
+// release is set, loads wait for it to close.
+type countingRepo struct {
+	repository.UserRepository
+
+	loads   atomic.Int64
+	release chan struct{}
+	name    atomic.Value
+}
+
+func newCountingRepo() *countingRepo {
+	r := &countingRepo{}
+	r.name.Store("Alice")
+	return r
+}
+
+func (r *countingRepo) GetByID(_ context.Context, id int64) (*modules.User, error) {
+	r.loads.Add(1)
+	if r.release != nil {
+		<-r.release
+	}
+	return &modules.User{ID: id, Name: r.name.Load().(string)}, nil
+}
+
+func (r *countingRepo) Update(_ context.Context, user *modules.User) error {
+	r.name.Store(user.Name)
+	return nil
+}
+
+func TestUserRepositoryCachesReads(t *testing.T) {
+	ctx := context.Background()
+	next := newCountingRepo()
+	repo := NewUserRepository(next, NewLRU(100), time.Minute)
+
+	for i := 0; i < 3; i++ {
+		if u, err := repo.GetByID(ctx, 1); err != nil || u.Name != "Alice" {
+			t.Fatalf("GetByID = %+v, %v", u, err)
+		}
+	}
+	if n := next.loads.Load(); n != 1 {
+		t.Errorf("loads = %d, want 1", n)
+	}
+	if s := repo.Stats(); s.Hits != 2 || s.Misses != 1 || s.Errors != 0 {
+		t.Errorf("Stats = %+v, want 2 hits and 1 miss", s)
+	}
+}
+
+func TestUserRepositoryWriteInvalidates(t *testing.T) {
+	ctx := context.Background()
+	next := newCountingRepo()
+	repo := NewUserRepository(next, NewLRU(100), time.Minute)
+
+	repo.GetByID(ctx, 1)
+	if err := repo.Update(ctx, &modules.User{ID: 1, Name: "Alicia"}); err != nil {
+		t.Fatal(err)
+	}
+	u, err := repo.GetByID(ctx, 1)
+	if err != nil || u.Name != "Alicia" {
+		t.Fatalf("GetByID after update = %+v, %v; want the new name", u, err)
+	}
+	if s := repo.Stats(); s.Invalidations != 1 || s.Misses != 2 {
+		t.Errorf("Stats = %+v, want 1 invalidation and 2 misses", s)
+	}
+
+	repo.Invalidate(ctx)
+	repo.GetByID(ctx, 1)
+	if n := next.loads.Load(); n != 3 {
+		t.Errorf("loads = %d, want 3 after an explicit invalidation", n)
+	}
+}
+
+func TestUserRepositoryExpiresReads(t *testing.T) {
+	ctx := context.Background()
+	next := newCountingRepo()
+	repo := NewUserRepository(next, NewLRU(100), 10*time.Millisecond)
+
+	repo.GetByID(ctx, 1)
+	time.Sleep(20 * time.Millisecond)
+	repo.GetByID(ctx, 1)
+	if n := next.loads.Load(); n != 2 {
+		t.Errorf("loads = %d, want 2 after the ttl", n)
+	}
+}
+
+func TestUserRepositoryCollapsesConcurrentMisses(t *testing.T) {
+	const callers = 10
+	ctx := context.Background()
+	next := newCountingRepo()
+	next.release = make(chan struct{})
+	repo := NewUserRepository(next, NewLRU(100), time.Minute)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := repo.GetByID(ctx, 1); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	// Every caller has missed once the counter says so; give them a moment
+	// to join the load before letting it finish.
+	for repo.Stats().Misses < callers {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(next.release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+	if n := next.loads.Load(); n != 1 {
+		t.Errorf("loads = %d, want concurrent misses to share 1", n)
+	}
+}
@@ -3,26 +3,85 @@ package repository
 import (
 	"context"
 	"practice4/practice-4/internal/repository/_postgres"
+	"practice4/practice-4/internal/repository/_postgres/outbox"
 	"practice4/practice-4/internal/repository/_postgres/users"
+	"practice4/practice-4/internal/repository/_postgres/webhooks"
 	"practice4/practice-4/pkg/modules"
+	"time"
 )
 
 type UserRepository interface {
-	GetAll(ctx context.Context, limit, offset int64) ([]modules.User, error)
-	CountUsers(ctx context.Context) (int64, error)
+	GetAll(ctx context.Context, filter modules.UserFilter, limit, offset int64) ([]modules.User, error)
+	CountUsers(ctx context.Context, filter modules.UserFilter) (int64, error)
+	// EstimateUsers approximates CountUsers cheaply; a negative result means
+	// no estimate is available.
+	EstimateUsers(ctx context.Context, filter modules.UserFilter) (int64, error)
 	GetByID(ctx context.Context, id int64) (*modules.User, error)
 	Create(ctx context.Context, user *modules.User) (int64, error)
 	Update(ctx context.Context, user *modules.User) error
 	Delete(ctx context.Context, id int64) error
 	CreateUserWithAudit(ctx context.Context, user *modules.User) (int64, error)
+	Restore(ctx context.Context, id int64) error
+	ListAuditLogs(ctx context.Context, filter modules.AuditLogFilter) ([]modules.AuditLog, error)
+	ListAuditLogsByUsers(ctx context.Context, userIDs []int64, perUser int64) ([]modules.AuditLog, error)
+	// Search returns up to limit users matching query, best first, without
+	// highlights. In-memory implementations can use search.Users.
+	Search(ctx context.Context, query string, limit int64) ([]modules.UserSearchResult, error)
+}
+
+// Truncater is implemented by repositories that can remove all users at once.
+type Truncater interface {
+	Truncate(ctx context.Context) error
+}
+
+// OutboxRepository drains the events that user mutations record in the
+// outbox.
+type OutboxRepository interface {
+	ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]modules.Event, error)
+	RecordAttempt(ctx context.Context, event *modules.Event) error
+}
+
+type WebhookRepository interface {
+	CreateSubscription(ctx context.Context, sub *modules.WebhookSubscription) (*modules.WebhookSubscription, error)
+	ListSubscriptions(ctx context.Context) ([]modules.WebhookSubscription, error)
+	GetSubscription(ctx context.Context, id int64) (*modules.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, id int64) error
+	EnqueueDeliveries(ctx context.Context, event modules.Event, body []byte) (int64, error)
+	ListDeliveries(ctx context.Context, subscriptionID int64, filter modules.DeliveryFilter) ([]modules.WebhookDelivery, error)
+	GetDelivery(ctx context.Context, subscriptionID, id int64) (*modules.WebhookDelivery, error)
+	ReplayDelivery(ctx context.Context, subscriptionID, id int64) (*modules.WebhookDelivery, error)
+	ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]modules.DueDelivery, error)
+	RecordAttempt(ctx context.Context, delivery *modules.WebhookDelivery) error
+}
+
+// ChangeFeed reports user changes as they are committed, across every
+// instance sharing the database.
+type ChangeFeed interface {
+	ListenChanges(ctx context.Context, handle func(modules.AuditLog)) error
+}
+
+// TxManager runs a unit of work atomically: every repository call made with
+// the context passed to fn shares one transaction, committed when fn returns
+// nil. fn may be retried after a serialization failure or deadlock.
+type TxManager interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
 }
 
 type Repositories struct {
-	Users UserRepository
+	Tx       TxManager
+	Users    UserRepository
+	Changes  ChangeFeed
+	Outbox   OutboxRepository
+	Webhooks WebhookRepository
 }
 
-func NewRepositories(db *_postgres.Dialect) *Repositories {
+func NewRepositories(db *_postgres.Dialect, cfg *modules.PostgreConfig) *Repositories {
+	userRepo := users.NewUserRepository(db, cfg)
 	return &Repositories{
-		Users: users.NewUserRepository(db),
+		Tx:       _postgres.NewTxManager(db, cfg),
+		Users:    userRepo,
+		Changes:  userRepo,
+		Outbox:   outbox.NewOutboxRepository(db),
+		Webhooks: webhooks.NewWebhookRepository(db, cfg),
 	}
 }
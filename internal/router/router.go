@@ -1,32 +1,129 @@
 package router
 
 import (
+	"expvar"
+	"fmt"
 	"net/http"
 	"practice4/practice-4/internal/handler"
 	"practice4/practice-4/internal/middleware"
+	"practice4/practice-4/internal/openapi"
+)
 
-	_ "practice4/practice-4/docs"
+// Handlers groups the handlers the router mounts.
+type Handlers struct {
+	Users      *handler.UserHandler
+	Webhooks   *handler.WebhookHandler
+	UserEvents http.Handler
+	GraphQL    http.Handler
+}
 
-	httpSwagger "github.com/swaggo/http-swagger"
-)
+type route struct {
+	pattern string
+	handler func(hs Handlers) http.Handler
+}
+
+func userRoute(pattern string, fn func(h *handler.UserHandler) http.HandlerFunc) route {
+	return route{pattern, func(hs Handlers) http.Handler { return fn(hs.Users) }}
+}
+
+func webhookRoute(pattern string, fn func(h *handler.WebhookHandler) http.HandlerFunc) route {
+	return route{pattern, func(hs Handlers) http.Handler { return fn(hs.Webhooks) }}
+}
+
+var publicRoutes = []route{
+	{"GET /health", func(Handlers) http.Handler { return http.HandlerFunc(health) }},
+	{"GET /openapi.json", func(Handlers) http.Handler { return openapi.Handler() }},
+}
+
+var userRoutes = []route{
+	userRoute("GET /users", func(h *handler.UserHandler) http.HandlerFunc { return h.GetAll }),
+	userRoute("GET /users/search", func(h *handler.UserHandler) http.HandlerFunc { return h.Search }),
+	userRoute("GET /users/{id}", func(h *handler.UserHandler) http.HandlerFunc { return h.GetByID }),
+	userRoute("POST /users", func(h *handler.UserHandler) http.HandlerFunc { return h.Create }),
+	userRoute("POST /users/audit", func(h *handler.UserHandler) http.HandlerFunc { return h.CreateWithAudit }),
+	userRoute("PUT /users/{id}", func(h *handler.UserHandler) http.HandlerFunc { return h.Update }),
+	userRoute("DELETE /users/{id}", func(h *handler.UserHandler) http.HandlerFunc { return h.Delete }),
+	userRoute("POST /users/{id}/restore", func(h *handler.UserHandler) http.HandlerFunc { return h.Restore }),
+	userRoute("GET /audit-logs", func(h *handler.UserHandler) http.HandlerFunc { return h.ListAuditLogs }),
+}
+
+var webhookRoutes = []route{
+	webhookRoute("POST /webhooks", func(h *handler.WebhookHandler) http.HandlerFunc { return h.Create }),
+	webhookRoute("GET /webhooks", func(h *handler.WebhookHandler) http.HandlerFunc { return h.List }),
+	webhookRoute("GET /webhooks/{id}", func(h *handler.WebhookHandler) http.HandlerFunc { return h.Get }),
+	webhookRoute("DELETE /webhooks/{id}", func(h *handler.WebhookHandler) http.HandlerFunc { return h.Delete }),
+	webhookRoute("GET /webhooks/{id}/deliveries", func(h *handler.WebhookHandler) http.HandlerFunc { return h.ListDeliveries }),
+	webhookRoute("GET /webhooks/{id}/deliveries/{deliveryID}", func(h *handler.WebhookHandler) http.HandlerFunc { return h.GetDelivery }),
+	webhookRoute("POST /webhooks/{id}/deliveries/{deliveryID}/replay", func(h *handler.WebhookHandler) http.HandlerFunc { return h.Replay }),
+}
+
+// rawRoutes fix their own response format, so they bypass content
+// negotiation.
+var rawRoutes = []route{
+	{"GET /users/events", func(hs Handlers) http.Handler { return hs.UserEvents }},
+	{"GET /debug/vars", func(Handlers) http.Handler { return http.HandlerFunc(debugVars) }},
+	{"GET /graphql", func(hs Handlers) http.Handler { return hs.GraphQL }},
+	{"POST /graphql", func(hs Handlers) http.Handler { return hs.GraphQL }},
+}
+
+// Patterns lists the API routes, for checking them against the OpenAPI spec.
+func Patterns() []string {
+	var patterns []string
+	for _, group := range [][]route{publicRoutes, userRoutes, webhookRoutes, rawRoutes} {
+		for _, rt := range group {
+			patterns = append(patterns, rt.pattern)
+		}
+	}
+	return patterns
+}
+
+// NewRouter wires the API routes. A nil validator disables OpenAPI
+// validation.
+func NewRouter(hs Handlers, authkey string, v *openapi.Validator) http.Handler {
+	validate := func(next http.Handler) http.Handler { return next }
+	if v != nil {
+		validate = v.Middleware
+	}
 
-func NewRouter(h *handler.UserHandler, authkey string) http.Handler {
 	authedMux := http.NewServeMux()
-	authedMux.HandleFunc("GET /users", h.GetAll)
-	authedMux.HandleFunc("GET /users/{id}", h.GetByID)
-	authedMux.HandleFunc("POST /users", h.Create)
-	authedMux.HandleFunc("POST /users/audit", h.CreateWithAudit)
-	authedMux.HandleFunc("PUT /users/{id}", h.Update)
-	authedMux.HandleFunc("DELETE /users/{id}", h.Delete)
+	for _, group := range [][]route{userRoutes, webhookRoutes} {
+		for _, rt := range group {
+			authedMux.Handle(rt.pattern, handler.Negotiate(rt.handler(hs)))
+		}
+	}
+	for _, rt := range rawRoutes {
+		authedMux.Handle(rt.pattern, rt.handler(hs))
+	}
 
 	mux := http.NewServeMux()
-	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"status":"ok"}`))
-	})
-	mux.Handle("/swagger/", httpSwagger.WrapHandler)
-	mux.Handle("/", middleware.AuthMiddleware(authkey)(authedMux))
+	for _, rt := range publicRoutes {
+		mux.Handle(rt.pattern, validate(rt.handler(hs)))
+	}
+	mux.Handle("GET /swagger/", openapi.UIHandler())
+	mux.Handle("/", middleware.AuthMiddleware(authkey)(validate(authedMux)))
 
 	return middleware.LoggingMiddleware(mux)
 }
+
+func health(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"status":"ok"}`))
+}
+
+// publishedVars are the expvar variables /debug/vars serves. The others,
+// such as cmdline with the database URL, must not leave the process.
+var publishedVars = []string{"user_cache"}
+
+func debugVars(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, "{")
+	sep := ""
+	for _, name := range publishedVars {
+		if v := expvar.Get(name); v != nil {
+			fmt.Fprintf(w, "%s%q: %s", sep, name, v)
+			sep = ", "
+		}
+	}
+	fmt.Fprint(w, "}\n")
+}
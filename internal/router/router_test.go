@@ -0,0 +1,38 @@
+package router
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestDebugVarsServesOnlyPublishedVars(t *testing.T) {
+	// The server publishes user_cache only when caching is on.
+	if expvar.Get("user_cache") == nil {
+		expvar.Publish("user_cache", expvar.Func(func() any { return map[string]int{"hits": 1} }))
+	}
+	req, err := http.NewRequest(http.MethodGet, "/debug/vars", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	debugVars(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	var vars map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &vars); err != nil {
+		t.Fatalf("body %s: %v", rec.Body, err)
+	}
+	var names []string
+	for name := range vars {
+		names = append(names, name)
+	}
+	if !slices.Equal(names, []string{"user_cache"}) {
+		t.Errorf("/debug/vars serves %v, want only user_cache", names)
+	}
+}
@@ -0,0 +1,83 @@
+package search_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"practice4/practice-4/internal/config"
+	"practice4/practice-4/internal/repository/_postgres"
+	"practice4/practice-4/internal/repository/_postgres/users"
+	"practice4/practice-4/internal/search"
+	"practice4/practice-4/pkg/modules"
+)
+
+// TestSearchAgreesWithPackage checks that Postgres ranks users roughly as
+// package search does: every user Postgres finds is found in memory too, and
+// both put the same user first. Package search also accepts misspellings
+// that pg_trgm's stricter word similarity threshold rejects, so it may find
+// more. It lives here rather than beside the repository because ./... skips
+// directories starting with _, and runs against a migrated scratch database,
+// whose users it replaces, when one is given:
+//
+//	TEST_DATABASE_URL=postgres://... go test ./internal/search
+func TestSearchAgreesWithPackage(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	ctx := context.Background()
+	cfg := config.Default().Postgres
+	cfg.URL = url
+	db, err := _postgres.NewPGXDialect(ctx, &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	repo := users.NewUserRepository(db, &cfg)
+	if err := repo.Truncate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var stored []modules.User
+	for _, u := range []modules.User{
+		{Name: "Alice Smyth", Email: "alice@example.com"},
+		{Name: "Bob Jones", Email: "bob@example.org"},
+		{Name: "Carol Smith", Email: "carol.smith@example.com"},
+		{Name: "Dave Smith", Email: "dave@example.net"},
+		{Name: "Frank Miller", Email: "frank@example.com"},
+		{Name: "Grace Garcia", Email: "grace.garcia@example.org"},
+	} {
+		id, err := repo.Create(ctx, &u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u.ID = id
+		stored = append(stored, u)
+	}
+
+	for _, query := range []string{"smith", "alice", "ali", "example.org", "grace garcia", "miler"} {
+		t.Run(query, func(t *testing.T) {
+			pg, err := repo.Search(ctx, query, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mem := search.Users(stored, query, 10)
+			if len(pg) == 0 || len(mem) == 0 {
+				t.Fatalf("Postgres found %d users and package search %d, want both to find some", len(pg), len(mem))
+			}
+			if pg[0].User.ID != mem[0].User.ID {
+				t.Errorf("best match is %q in Postgres but %q in package search", pg[0].User.Name, mem[0].User.Name)
+			}
+			found := map[int64]bool{}
+			for _, r := range mem {
+				found[r.User.ID] = true
+			}
+			for _, r := range pg {
+				if !found[r.User.ID] {
+					t.Errorf("Postgres matched %q, package search did not", r.User.Name)
+				}
+			}
+		})
+	}
+}
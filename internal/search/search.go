@@ -0,0 +1,175 @@
+// Package search matches, ranks and highlights users in memory. It
+// approximates the full-text and trigram matching of the Postgres
+// repository for in-memory repositories, and highlights results from
+// either.
+package search
+
+import (
+	"cmp"
+	"html"
+	"slices"
+	"strings"
+	"unicode"
+
+	"practice4/practice-4/pkg/modules"
+)
+
+// Words at least this similar to a query word count as misspellings of it.
+const similarityThreshold = 0.3
+
+// Users returns up to limit of users matching query, best first.
+func Users(users []modules.User, query string, limit int) []modules.UserSearchResult {
+	results := []modules.UserSearchResult{}
+	for _, u := range users {
+		if u.DeletedAt != nil {
+			continue
+		}
+		if rank := Rank(query, u.Name) + Rank(query, u.Email); rank > 0 {
+			results = append(results, modules.UserSearchResult{User: u, Rank: rank})
+		}
+	}
+	slices.SortFunc(results, func(a, b modules.UserSearchResult) int {
+		if c := cmp.Compare(b.Rank, a.Rank); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.User.ID, b.User.ID)
+	})
+	return results[:min(limit, len(results))]
+}
+
+// Rank scores how well text matches query between 0 and 1: 1 if it contains
+// the whole query, otherwise the mean over query words of their best match
+// among the words of text, where containing the query word scores 1 and a
+// misspelling scores its trigram similarity.
+func Rank(query, text string) float64 {
+	q := strings.ToLower(strings.TrimSpace(query))
+	if q == "" {
+		return 0
+	}
+	if strings.Contains(strings.ToLower(text), q) {
+		return 1
+	}
+	terms := words(q)
+	if len(terms) == 0 {
+		return 0
+	}
+	var total float64
+	for _, term := range terms {
+		total += bestMatch(term, words(text))
+	}
+	return total / float64(len(terms))
+}
+
+// Highlight returns text, HTML-escaped, with the parts matching query
+// wrapped in <mark> tags: occurrences of query words, and whole words that
+// are misspellings of one.
+func Highlight(text, query string) string {
+	terms := words(strings.ToLower(query))
+	var b strings.Builder
+	runes := []rune(text)
+	for start := 0; start < len(runes); {
+		end := start + 1
+		inWord := isWordRune(runes[start])
+		for end < len(runes) && isWordRune(runes[end]) == inWord {
+			end++
+		}
+		chunk := runes[start:end]
+		if inWord {
+			highlightWord(&b, chunk, terms)
+		} else {
+			b.WriteString(html.EscapeString(string(chunk)))
+		}
+		start = end
+	}
+	return b.String()
+}
+
+func highlightWord(b *strings.Builder, word []rune, terms [][]rune) {
+	lower := toLower(word)
+	misspelt := false
+	for _, term := range terms {
+		if i := index(lower, term); i >= 0 {
+			b.WriteString(html.EscapeString(string(word[:i])))
+			b.WriteString("<mark>" + html.EscapeString(string(word[i:i+len(term)])) + "</mark>")
+			b.WriteString(html.EscapeString(string(word[i+len(term):])))
+			return
+		}
+		if similarity(lower, term) >= similarityThreshold {
+			misspelt = true
+		}
+	}
+	if misspelt {
+		b.WriteString("<mark>" + html.EscapeString(string(word)) + "</mark>")
+		return
+	}
+	b.WriteString(html.EscapeString(string(word)))
+}
+
+func bestMatch(term []rune, candidates [][]rune) float64 {
+	var best float64
+	for _, w := range candidates {
+		if index(w, term) >= 0 {
+			return 1
+		}
+		if s := similarity(term, w); s >= similarityThreshold && s > best {
+			best = s
+		}
+	}
+	return best
+}
+
+// similarity is the share of trigrams two words have in common, as
+// pg_trgm computes it: words are padded with two spaces in front and one
+// behind.
+func similarity(a, b []rune) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	shared := 0
+	for t := range ta {
+		if _, ok := tb[t]; ok {
+			shared++
+		}
+	}
+	if union := len(ta) + len(tb) - shared; union > 0 {
+		return float64(shared) / float64(union)
+	}
+	return 0
+}
+
+func trigrams(word []rune) map[string]struct{} {
+	padded := append(append([]rune("  "), word...), ' ')
+	set := make(map[string]struct{}, len(padded))
+	for i := 0; i+3 <= len(padded); i++ {
+		set[string(padded[i:i+3])] = struct{}{}
+	}
+	return set
+}
+
+// words splits s into lower-cased runs of letters and digits.
+func words(s string) [][]rune {
+	var out [][]rune
+	for _, f := range strings.FieldsFunc(s, func(r rune) bool { return !isWordRune(r) }) {
+		out = append(out, toLower([]rune(f)))
+	}
+	return out
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func toLower(rs []rune) []rune {
+	out := make([]rune, len(rs))
+	for i, r := range rs {
+		out[i] = unicode.ToLower(r)
+	}
+	return out
+}
+
+func index(s, sub []rune) int {
+	for i := 0; i+len(sub) <= len(s); i++ {
+		if slices.Equal(s[i:i+len(sub)], sub) {
+			return i
+		}
+	}
+	return -1
+}
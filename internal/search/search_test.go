@@ -0,0 +1,103 @@
+package search
+
+import (
+	"math"
+	"testing"
+
+	"practice4/practice-4/pkg/modules"
+)
+
+func TestRank(t *testing.T) {
+	for _, tc := range []struct {
+		name, query, text string
+		want              float64
+	}{
+		{"empty query", "  ", "Alice Smith", 0},
+		{"whole query", "ice smi", "Alice Smith", 1},
+		{"case insensitive", "ALICE", "alice smith", 1},
+		{"words in any order", "smith alice", "Alice Smith", 1},
+		{"one of two words", "alice jones", "Alice Smith", 0.5},
+		{"word inside a word", "ali", "Alice", 1},
+		{"misspelling", "smyth", "Alice Smith", 1.0 / 3},
+		// "andrew" and "andyx" share 3 of 10 trigrams: exactly the threshold.
+		{"misspelling at threshold", "andrew", "Andyx Stone", 0.3},
+		// "andrew" and "andxyz" share 3 of 11.
+		{"just below threshold", "andrew", "Andxyz Stone", 0},
+		{"no match", "bob", "Alice Smith", 0},
+		{"punctuation only", "@.", "alice@example.com", 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Rank(tc.query, tc.text); math.Abs(got-tc.want) > 1e-9 {
+				t.Errorf("Rank(%q, %q) = %v, want %v", tc.query, tc.text, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestUsers(t *testing.T) {
+	deleted := modules.User{ID: 9, Name: "Smith Deleted", Email: "gone@example.com"}
+	deleted.DeletedAt = &deleted.CreatedAt
+	users := []modules.User{
+		{ID: 1, Name: "Bob Jones", Email: "bob@example.com"},
+		{ID: 2, Name: "Alice Smyth", Email: "alice@example.com"},
+		{ID: 3, Name: "Carol Smith", Email: "carol.smith@example.com"},
+		{ID: 4, Name: "Dave Smith", Email: "dave@example.com"},
+		deleted,
+	}
+
+	for _, tc := range []struct {
+		name  string
+		query string
+		limit int
+		want  []int64
+	}{
+		// Carol matches in name and email, Dave in name only, Alice by a
+		// misspelling; ties go to the lower ID.
+		{"ranked", "smith", 10, []int64{3, 4, 2}},
+		{"limit", "smith", 2, []int64{3, 4}},
+		{"zero limit", "smith", 0, []int64{}},
+		{"no match", "zed", 10, []int64{}},
+		{"email", "example", 10, []int64{1, 2, 3, 4}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Users(users, tc.query, tc.limit)
+			if len(got) != len(tc.want) {
+				t.Fatalf("Users(%q, %d) returned %d results, want %v", tc.query, tc.limit, len(got), tc.want)
+			}
+			for i, r := range got {
+				if r.User.ID != tc.want[i] {
+					t.Fatalf("Users(%q, %d)[%d] = user %d, want %v", tc.query, tc.limit, i, r.User.ID, tc.want)
+				}
+				if i > 0 && r.Rank > got[i-1].Rank {
+					t.Fatalf("results are not ordered by rank: %v after %v", r.Rank, got[i-1].Rank)
+				}
+			}
+		})
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	for _, tc := range []struct {
+		name, text, query, want string
+	}{
+		{"no match", "Alice Smith", "bob", "Alice Smith"},
+		{"part of a word", "Alice Smith", "ali", "<mark>Ali</mark>ce Smith"},
+		{"several words", "Alice Smith", "smith alice", "<mark>Alice</mark> <mark>Smith</mark>"},
+		{"misspelt word", "Alice Smith", "smyth", "Alice <mark>Smith</mark>"},
+		{"email", "alice@example.com", "example", "alice@<mark>example</mark>.com"},
+		{"script in name", "<script>alert(1)</script>", "alert",
+			"&lt;script&gt;<mark>alert</mark>(1)&lt;/script&gt;"},
+		{"tag name", "<script>", "script", "&lt;<mark>script</mark>&gt;"},
+		{"match across &", "AT&T Labs", "at&t", "<mark>AT</mark>&amp;<mark>T</mark> Labs"},
+		// The entity is produced by escaping, not by the text, so a query
+		// must not mark inside it.
+		{"entity is not matched", "Tom & Jerry", "amp", "Tom &amp; Jerry"},
+		{"quotes", `O'Brien "Bo"`, "bo", `O&#39;Brien &#34;<mark>Bo</mark>&#34;`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Highlight(tc.text, tc.query); got != tc.want {
+				t.Errorf("Highlight(%q, %q) = %q, want %q", tc.text, tc.query, got, tc.want)
+			}
+		})
+	}
+}
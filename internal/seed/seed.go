@@ -0,0 +1,120 @@
+package seed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"practice4/practice-4/internal/repository"
+	"practice4/practice-4/pkg/apperrors"
+	"practice4/practice-4/pkg/modules"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	firstNames = []string{"Alice", "Bob", "Carol", "Dave", "Erin", "Frank", "Grace", "Heidi", "Ivan", "Judy",
+		"Mallory", "Niaj", "Olivia", "Peggy", "Rupert", "Sybil", "Trent", "Victor", "Walter", "Yasmin"}
+	lastNames = []string{"Anderson", "Brown", "Chen", "Diaz", "Evans", "Fischer", "Garcia", "Hughes", "Ivanova",
+		"Jones", "Kim", "Lopez", "Miller", "Nakamura", "Okafor", "Patel", "Quinn", "Rossi", "Smith", "Tanaka"}
+	domains = []string{"example.com", "example.org", "example.net"}
+)
+
+type fixtureFile struct {
+	Users []modules.UserInput `json:"users" yaml:"users"`
+}
+
+// LoadFixtures reads users from a YAML or JSON file of the form
+// {"users": [{"name": ..., "email": ...}]}.
+func LoadFixtures(path string) ([]modules.User, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var f fixtureFile
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err = dec.Decode(&f); err == io.EOF {
+			err = nil // an empty document holds no users
+		}
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&f)
+	default:
+		return nil, fmt.Errorf("%s: unsupported fixture format, use .yaml, .yml or .json", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	users := make([]modules.User, len(f.Users))
+	for i, u := range f.Users {
+		users[i] = modules.User{Name: u.Name, Email: u.Email}
+	}
+	return users, nil
+}
+
+// Fake generates n users. The same seed always yields the same users.
+func Fake(n int, seed int64) []modules.User {
+	rnd := rand.New(rand.NewSource(seed))
+
+	users := make([]modules.User, n)
+	for i := range users {
+		first := firstNames[rnd.Intn(len(firstNames))]
+		last := lastNames[rnd.Intn(len(lastNames))]
+		users[i] = modules.User{
+			Name:  first + " " + last,
+			Email: fmt.Sprintf("%s.%s%d@%s", strings.ToLower(first), strings.ToLower(last), i+1, domains[rnd.Intn(len(domains))]),
+		}
+	}
+	return users
+}
+
+// Load writes users through repo, optionally truncating existing data first.
+// Every user is validated before anything is truncated or written, so a bad
+// fixture leaves the database untouched.
+func Load(ctx context.Context, repo repository.UserRepository, users []modules.User, truncate bool) (int, error) {
+	for i, u := range users {
+		if err := validate(u); err != nil {
+			return 0, fmt.Errorf("seed: user %d (%s): %w", i+1, u.Email, err)
+		}
+	}
+	if truncate {
+		t, ok := repo.(repository.Truncater)
+		if !ok {
+			return 0, errors.New("seed: repository does not support truncation")
+		}
+		if err := t.Truncate(ctx); err != nil {
+			return 0, fmt.Errorf("seed: %w", err)
+		}
+	}
+
+	for i := range users {
+		if _, err := repo.Create(ctx, &users[i]); err != nil {
+			return i, fmt.Errorf("seed: user %d (%s): %w", i+1, users[i].Email, err)
+		}
+	}
+	return len(users), nil
+}
+
+// validate applies the checks the API makes on created users.
+func validate(u modules.User) error {
+	if strings.TrimSpace(u.Name) == "" {
+		return fmt.Errorf("%w: name is required", apperrors.ErrValidation)
+	}
+	if addr, err := mail.ParseAddress(u.Email); err != nil || addr.Address != u.Email {
+		return fmt.Errorf("%w: invalid email %q", apperrors.ErrValidation, u.Email)
+	}
+	return nil
+}
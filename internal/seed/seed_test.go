@@ -0,0 +1,147 @@
+package seed
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"practice4/practice-4/internal/repository"
+	"practice4/practice-4/pkg/apperrors"
+	"practice4/practice-4/pkg/modules"
+)
+
+func TestLoadFixtures(t *testing.T) {
+	for _, tc := range []struct {
+		name, file, data string
+		users            int
+		err              string
+	}{
+		{"yaml", "users.yaml", "users:\n  - name: Alice\n    email: alice@example.com\n", 1, ""},
+		{"yml", "users.yml", "users:\n  - {name: Alice, email: a@example.com}\n  - {name: Bob, email: b@example.com}\n", 2, ""},
+		{"empty yaml", "users.yaml", "", 0, ""},
+		{"json", "users.json", `{"users": [{"name": "Alice", "email": "alice@example.com"}]}`, 1, ""},
+		{"unknown yaml field", "users.yaml", "users:\n  - name: Alice\n    emial: alice@example.com\n", 0, "emial"},
+		{"unknown top-level yaml field", "users.yaml", "user:\n  - name: Alice\n", 0, "user"},
+		{"unknown json field", "users.json", `{"users": [{"name": "Alice", "emial": "alice@example.com"}]}`, 0, "emial"},
+		{"unsupported format", "users.txt", "", 0, "unsupported fixture format"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tc.file)
+			if err := os.WriteFile(path, []byte(tc.data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			users, err := LoadFixtures(path)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("LoadFixtures = %v, want an error mentioning %q", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(users) != tc.users {
+				t.Fatalf("LoadFixtures returned %d users, want %d", len(users), tc.users)
+			}
+		})
+	}
+}
+
+func TestFakeIsDeterministic(t *testing.T) {
+	a, b := Fake(50, 42), Fake(50, 42)
+	if !reflect.DeepEqual(a, b) {
+		t.Fatal("Fake returned different users for the same seed")
+	}
+	if reflect.DeepEqual(a, Fake(50, 43)) {
+		t.Error("Fake returned the same users for different seeds")
+	}
+	emails := map[string]bool{}
+	for _, u := range a {
+		if err := validate(u); err != nil {
+			t.Errorf("fake user %+v: %v", u, err)
+		}
+		if emails[u.Email] {
+			t.Errorf("duplicate fake email %s", u.Email)
+		}
+		emails[u.Email] = true
+	}
+}
+
+// memRepo is a UserRepository that keeps created users in memory and fails
+// to create the user with email failOn.
+type memRepo struct {
+	repository.UserRepository
+	users     []modules.User
+	failOn    string
+	truncated bool
+}
+
+func (r *memRepo) Create(_ context.Context, u *modules.User) (int64, error) {
+	if u.Email == r.failOn {
+		return 0, apperrors.ErrConflict
+	}
+	r.users = append(r.users, *u)
+	return int64(len(r.users)), nil
+}
+
+// truncatingRepo also implements repository.Truncater.
+type truncatingRepo struct{ *memRepo }
+
+func (r truncatingRepo) Truncate(context.Context) error {
+	r.users, r.truncated = nil, true
+	return nil
+}
+
+func TestLoad(t *testing.T) {
+	existing := []modules.User{{Name: "Old", Email: "old@example.com"}}
+	users := []modules.User{{Name: "Alice", Email: "alice@example.com"}, {Name: "Bob", Email: "bob@example.com"}}
+
+	for _, tc := range []struct {
+		name      string
+		users     []modules.User
+		truncate  bool
+		canTrunc  bool
+		failOn    string
+		loaded    int
+		stored    int
+		truncated bool
+		err       string
+	}{
+		{"append", users, false, true, "", 2, 3, false, ""},
+		{"truncate", users, true, true, "", 2, 2, true, ""},
+		{"truncate unsupported", users, true, false, "", 0, 1, false, "does not support truncation"},
+		{"create fails", users, false, true, "bob@example.com", 1, 2, false, "user 2 (bob@example.com)"},
+		{"missing name", []modules.User{users[0], {Email: "x@example.com"}}, true, true, "", 0, 1, false, "name is required"},
+		{"invalid email", []modules.User{users[0], {Name: "X", Email: "not-an-email"}}, true, true, "", 0, 1, false, `invalid email "not-an-email"`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mem := &memRepo{users: append([]modules.User(nil), existing...), failOn: tc.failOn}
+			var repo repository.UserRepository = mem
+			if tc.canTrunc {
+				repo = truncatingRepo{mem}
+			}
+			n, err := Load(context.Background(), repo, tc.users, tc.truncate)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("Load = %v, want an error mentioning %q", err, tc.err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if n != tc.loaded || len(mem.users) != tc.stored || mem.truncated != tc.truncated {
+				t.Errorf("loaded %d, stored %d, truncated %v; want %d, %d, %v", n, len(mem.users), mem.truncated, tc.loaded, tc.stored, tc.truncated)
+			}
+		})
+	}
+}
+
+func TestLoadValidationErrorWrapsErrValidation(t *testing.T) {
+	_, err := Load(context.Background(), &memRepo{}, []modules.User{{Name: "X"}}, false)
+	if !errors.Is(err, apperrors.ErrValidation) {
+		t.Errorf("Load = %v, want ErrValidation", err)
+	}
+}
@@ -3,13 +3,44 @@ package usecase
 import (
 	"context"
 	"practice4/practice-4/pkg/modules"
+	"time"
 )
 
 type UserUsecase interface {
-	GetAll(ctx context.Context, limit, offset int64) (*modules.PaginatedUsers, error)
+	GetAll(ctx context.Context, filter modules.UserFilter, limit, offset int64) (*modules.PaginatedUsers, error)
+	// ListUsers returns up to limit users matching filter in ID order
+	// without counting them, for callers that walk every user by AfterID.
+	ListUsers(ctx context.Context, filter modules.UserFilter, limit int64) ([]modules.User, error)
 	GetByID(ctx context.Context, id int64) (*modules.User, error)
 	Create(ctx context.Context, user *modules.User) (int64, error)
 	Update(ctx context.Context, user *modules.User) error
 	Delete(ctx context.Context, id int64) error
 	CreateUserWithAudit(ctx context.Context, user *modules.User) (int64, error)
+	Restore(ctx context.Context, id int64) error
+	ListAuditLogs(ctx context.Context, filter modules.AuditLogFilter) ([]modules.AuditLog, error)
+	// AuditLogsByUsers returns the last perUser audit log entries of each
+	// user, keyed by user ID, fetched together for batching callers.
+	AuditLogsByUsers(ctx context.Context, userIDs []int64, perUser int64) (map[int64][]modules.AuditLog, error)
+	// Search finds users by partial or misspelled name or email, best match
+	// first, with the matches highlighted.
+	Search(ctx context.Context, query string, limit int64) (*modules.UserSearchResults, error)
+}
+
+type WebhookUsecase interface {
+	Subscribe(ctx context.Context, input modules.WebhookInput) (*modules.WebhookSubscription, error)
+	ListSubscriptions(ctx context.Context) ([]modules.WebhookSubscription, error)
+	GetSubscription(ctx context.Context, id int64) (*modules.WebhookSubscription, error)
+	Unsubscribe(ctx context.Context, id int64) error
+	ListDeliveries(ctx context.Context, subscriptionID int64, filter modules.DeliveryFilter) ([]modules.WebhookDelivery, error)
+	GetDelivery(ctx context.Context, subscriptionID, id int64) (*modules.WebhookDelivery, error)
+	// ReplayDelivery queues a new attempt at a past delivery, typically a
+	// dead one, and returns the new delivery.
+	ReplayDelivery(ctx context.Context, subscriptionID, id int64) (*modules.WebhookDelivery, error)
+}
+
+// CountCache stores user totals for the "cached" count strategy, such as a
+// cache.LRU.
+type CountCache interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
 }
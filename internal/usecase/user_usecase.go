@@ -2,36 +2,92 @@ package usecase
 
 import (
 	"context"
+	"fmt"
 	"practice4/practice-4/internal/repository"
+	"practice4/practice-4/internal/search"
 	"practice4/practice-4/pkg/apperrors"
 	"practice4/practice-4/pkg/modules"
+	"strconv"
+	"strings"
 )
 
+// Below this many rows an estimate saves little, so the count is exact.
+const exactCountBelow = 1000
+
 type userUsecase struct {
-	repo repository.UserRepository
+	repo       repository.UserRepository
+	tx         repository.TxManager
+	counts     modules.CountConfig
+	countCache CountCache
 }
 
-func NewUserUsecase(repo repository.UserRepository) UserUsecase {
-	return &userUsecase{repo: repo}
+// NewUserUsecase returns the user usecase. countCache keeps totals for the
+// "cached" count strategy; without one, that strategy counts exactly.
+func NewUserUsecase(repo repository.UserRepository, tx repository.TxManager, counts modules.CountConfig, countCache CountCache) UserUsecase {
+	return &userUsecase{repo: repo, tx: tx, counts: counts, countCache: countCache}
 }
 
 var _ UserUsecase = (*userUsecase)(nil)
 
-func (u *userUsecase) GetAll(ctx context.Context, limit, offset int64) (*modules.PaginatedUsers, error) {
-	users, err := u.repo.GetAll(ctx, limit, offset)
+func (u *userUsecase) GetAll(ctx context.Context, filter modules.UserFilter, limit, offset int64) (*modules.PaginatedUsers, error) {
+	users, err := u.repo.GetAll(ctx, filter, limit, offset)
 	if err != nil {
 		return nil, err
 	}
-	total, err := u.repo.CountUsers(ctx)
-	if err != nil {
-		return nil, err
-	}
-	return &modules.PaginatedUsers{
+	page := &modules.PaginatedUsers{
 		Users:  users,
-		Total:  total,
 		Limit:  limit,
 		Offset: offset,
-	}, nil
+	}
+	seen := offset + int64(len(users))
+	if int64(len(users)) < limit && (len(users) > 0 || offset == 0) {
+		// A short page is the last one, so it pins the total exactly.
+		page.Total = seen
+		return page, nil
+	}
+	page.Total, page.TotalIsEstimate, err = u.count(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	page.Total = max(page.Total, seen)
+	return page, nil
+}
+
+func (u *userUsecase) ListUsers(ctx context.Context, filter modules.UserFilter, limit int64) ([]modules.User, error) {
+	return u.repo.GetAll(ctx, filter, limit, 0)
+}
+
+// count returns how many users match filter using the configured strategy,
+// and whether the result is an estimate or a possibly stale cached count.
+func (u *userUsecase) count(ctx context.Context, filter modules.UserFilter) (int64, bool, error) {
+	switch u.counts.Strategy {
+	case "estimated":
+		estimate, err := u.repo.EstimateUsers(ctx, filter)
+		if err != nil {
+			return 0, false, err
+		}
+		if estimate >= exactCountBelow {
+			return estimate, true, nil
+		}
+	case "cached":
+		if u.countCache == nil {
+			break
+		}
+		key := filter.Name + "\x00" + filter.Email + "\x00" + strconv.FormatInt(filter.AfterID, 10)
+		if data, ok, _ := u.countCache.Get(ctx, key); ok {
+			if n, err := strconv.ParseInt(string(data), 10, 64); err == nil {
+				return n, true, nil
+			}
+		}
+		n, err := u.repo.CountUsers(ctx, filter)
+		if err != nil {
+			return 0, false, err
+		}
+		u.countCache.Set(ctx, key, strconv.AppendInt(nil, n, 10), u.counts.TTL)
+		return n, false, nil
+	}
+	n, err := u.repo.CountUsers(ctx, filter)
+	return n, false, err
 }
 
 func (u *userUsecase) GetByID(ctx context.Context, id int64) (*modules.User, error) {
@@ -45,11 +101,22 @@ func (u *userUsecase) Create(ctx context.Context, user *modules.User) (int64, er
 	return u.repo.Create(ctx, user)
 }
 
+// Update reads the user and writes the change in one transaction, skipping
+// the write, and so its event, when nothing changes.
 func (u *userUsecase) Update(ctx context.Context, user *modules.User) error {
 	if user.Name == "" || user.Email == "" {
 		return apperrors.ErrValidation
 	}
-	return u.repo.Update(ctx, user)
+	return u.tx.WithinTx(ctx, func(ctx context.Context) error {
+		current, err := u.repo.GetByID(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		if current.Name == user.Name && current.Email == user.Email {
+			return nil
+		}
+		return u.repo.Update(ctx, user)
+	})
 }
 
 func (u *userUsecase) Delete(ctx context.Context, id int64) error {
@@ -62,3 +129,64 @@ func (u *userUsecase) CreateUserWithAudit(ctx context.Context, user *modules.Use
 	}
 	return u.repo.CreateUserWithAudit(ctx, user)
 }
+
+func (u *userUsecase) Restore(ctx context.Context, id int64) error {
+	return u.repo.Restore(ctx, id)
+}
+
+func (u *userUsecase) ListAuditLogs(ctx context.Context, filter modules.AuditLogFilter) ([]modules.AuditLog, error) {
+	if filter.UserID < 0 || filter.AfterID < 0 || filter.AfterSeq < 0 {
+		return nil, apperrors.ErrValidation
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = 50
+	}
+	if filter.Limit > 500 {
+		filter.Limit = 500
+	}
+	return u.repo.ListAuditLogs(ctx, filter)
+}
+
+func (u *userUsecase) AuditLogsByUsers(ctx context.Context, userIDs []int64, perUser int64) (map[int64][]modules.AuditLog, error) {
+	if perUser <= 0 {
+		perUser = 20
+	}
+	if perUser > 500 {
+		perUser = 500
+	}
+	byUser := make(map[int64][]modules.AuditLog, len(userIDs))
+	if len(userIDs) == 0 {
+		return byUser, nil
+	}
+	logs, err := u.repo.ListAuditLogsByUsers(ctx, userIDs, perUser)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range logs {
+		byUser[l.UserID] = append(byUser[l.UserID], l)
+	}
+	return byUser, nil
+}
+
+const maxSearchQueryLen = 200
+
+func (u *userUsecase) Search(ctx context.Context, query string, limit int64) (*modules.UserSearchResults, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, fmt.Errorf("%w: q is required", apperrors.ErrValidation)
+	}
+	if len(query) > maxSearchQueryLen {
+		return nil, fmt.Errorf("%w: q must be at most %d bytes", apperrors.ErrValidation, maxSearchQueryLen)
+	}
+	results, err := u.repo.Search(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		results[i].Highlights = modules.UserHighlights{
+			Name:  search.Highlight(results[i].User.Name, query),
+			Email: search.Highlight(results[i].User.Email, query),
+		}
+	}
+	return &modules.UserSearchResults{Query: query, Results: results}, nil
+}
@@ -0,0 +1,102 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"practice4/practice-4/internal/repository"
+	"practice4/practice-4/pkg/apperrors"
+	"practice4/practice-4/pkg/modules"
+	"slices"
+)
+
+const minSecretLen = 16
+
+type webhookUsecase struct {
+	repo repository.WebhookRepository
+	tx   repository.TxManager
+}
+
+func NewWebhookUsecase(repo repository.WebhookRepository, tx repository.TxManager) WebhookUsecase {
+	return &webhookUsecase{repo: repo, tx: tx}
+}
+
+var _ WebhookUsecase = (*webhookUsecase)(nil)
+
+func (u *webhookUsecase) Subscribe(ctx context.Context, input modules.WebhookInput) (*modules.WebhookSubscription, error) {
+	target, err := url.Parse(input.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, fmt.Errorf("%w: url must be an absolute http or https URL", apperrors.ErrValidation)
+	}
+	if len(input.EventTypes) == 0 {
+		return nil, fmt.Errorf("%w: event_types must not be empty", apperrors.ErrValidation)
+	}
+	var types []string
+	for _, t := range input.EventTypes {
+		if !slices.Contains(modules.EventTypes, t) {
+			return nil, fmt.Errorf("%w: unknown event type %q", apperrors.ErrValidation, t)
+		}
+		if !slices.Contains(types, t) {
+			types = append(types, t)
+		}
+	}
+	if len(input.Secret) < minSecretLen {
+		return nil, fmt.Errorf("%w: secret must be at least %d characters", apperrors.ErrValidation, minSecretLen)
+	}
+
+	return u.repo.CreateSubscription(ctx, &modules.WebhookSubscription{
+		URL:        input.URL,
+		EventTypes: types,
+		Secret:     input.Secret,
+	})
+}
+
+func (u *webhookUsecase) ListSubscriptions(ctx context.Context) ([]modules.WebhookSubscription, error) {
+	return u.repo.ListSubscriptions(ctx)
+}
+
+func (u *webhookUsecase) GetSubscription(ctx context.Context, id int64) (*modules.WebhookSubscription, error) {
+	return u.repo.GetSubscription(ctx, id)
+}
+
+func (u *webhookUsecase) Unsubscribe(ctx context.Context, id int64) error {
+	return u.repo.DeleteSubscription(ctx, id)
+}
+
+func (u *webhookUsecase) ListDeliveries(ctx context.Context, subscriptionID int64, filter modules.DeliveryFilter) ([]modules.WebhookDelivery, error) {
+	switch filter.Status {
+	case "", modules.DeliveryPending, modules.DeliverySucceeded, modules.DeliveryDead:
+	default:
+		return nil, fmt.Errorf("%w: unknown status %q", apperrors.ErrValidation, filter.Status)
+	}
+	if filter.BeforeID < 0 {
+		return nil, apperrors.ErrValidation
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = 50
+	}
+	if filter.Limit > 500 {
+		filter.Limit = 500
+	}
+	var deliveries []modules.WebhookDelivery
+	err := u.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if _, err := u.repo.GetSubscription(ctx, subscriptionID); err != nil {
+			return err
+		}
+		var err error
+		deliveries, err = u.repo.ListDeliveries(ctx, subscriptionID, filter)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (u *webhookUsecase) GetDelivery(ctx context.Context, subscriptionID, id int64) (*modules.WebhookDelivery, error) {
+	return u.repo.GetDelivery(ctx, subscriptionID, id)
+}
+
+func (u *webhookUsecase) ReplayDelivery(ctx context.Context, subscriptionID, id int64) (*modules.WebhookDelivery, error) {
+	return u.repo.ReplayDelivery(ctx, subscriptionID, id)
+}
@@ -0,0 +1,72 @@
+package usersctl
+
+import (
+	"context"
+	"practice4/practice-4/internal/usecase"
+	"practice4/practice-4/pkg/client"
+	"practice4/practice-4/pkg/modules"
+)
+
+// httpUsecase implements usecase.UserUsecase against a running API server.
+type httpUsecase struct {
+	c *client.Client
+}
+
+var _ usecase.UserUsecase = (*httpUsecase)(nil)
+
+func (h *httpUsecase) GetAll(ctx context.Context, filter modules.UserFilter, limit, offset int64) (*modules.PaginatedUsers, error) {
+	return h.c.ListUsersMatching(ctx, filter, limit, offset)
+}
+
+func (h *httpUsecase) ListUsers(ctx context.Context, filter modules.UserFilter, limit int64) ([]modules.User, error) {
+	page, err := h.c.ListUsersMatching(ctx, filter, limit, 0)
+	if err != nil {
+		return nil, err
+	}
+	return page.Users, nil
+}
+
+func (h *httpUsecase) GetByID(ctx context.Context, id int64) (*modules.User, error) {
+	return h.c.GetUser(ctx, id)
+}
+
+func (h *httpUsecase) Create(ctx context.Context, user *modules.User) (int64, error) {
+	return h.c.CreateUser(ctx, modules.UserInput{Name: user.Name, Email: user.Email})
+}
+
+func (h *httpUsecase) CreateUserWithAudit(ctx context.Context, user *modules.User) (int64, error) {
+	return h.c.CreateUserWithAudit(ctx, modules.UserInput{Name: user.Name, Email: user.Email})
+}
+
+func (h *httpUsecase) Update(ctx context.Context, user *modules.User) error {
+	return h.c.UpdateUser(ctx, user.ID, modules.UserInput{Name: user.Name, Email: user.Email})
+}
+
+func (h *httpUsecase) Delete(ctx context.Context, id int64) error {
+	return h.c.DeleteUser(ctx, id)
+}
+
+func (h *httpUsecase) Restore(ctx context.Context, id int64) error {
+	return h.c.RestoreUser(ctx, id)
+}
+
+func (h *httpUsecase) Search(ctx context.Context, query string, limit int64) (*modules.UserSearchResults, error) {
+	return h.c.SearchUsers(ctx, query, limit)
+}
+
+func (h *httpUsecase) ListAuditLogs(ctx context.Context, filter modules.AuditLogFilter) ([]modules.AuditLog, error) {
+	return h.c.ListAuditLogs(ctx, filter)
+}
+
+// AuditLogsByUsers has no batch endpoint to use, so it asks per user.
+func (h *httpUsecase) AuditLogsByUsers(ctx context.Context, userIDs []int64, perUser int64) (map[int64][]modules.AuditLog, error) {
+	byUser := make(map[int64][]modules.AuditLog, len(userIDs))
+	for _, id := range userIDs {
+		logs, err := h.c.ListAuditLogs(ctx, modules.AuditLogFilter{UserID: id, Limit: perUser, Tail: true})
+		if err != nil {
+			return nil, err
+		}
+		byUser[id] = logs
+	}
+	return byUser, nil
+}
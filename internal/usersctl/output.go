@@ -0,0 +1,90 @@
+package usersctl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"practice4/practice-4/pkg/modules"
+	"text/tabwriter"
+	"time"
+)
+
+type printer interface {
+	users(users []modules.User) error
+	page(page *modules.PaginatedUsers) error
+	auditLogs(logs []modules.AuditLog) error
+	message(msg string) error
+}
+
+func newPrinter(format string, w io.Writer) (printer, error) {
+	switch format {
+	case "table":
+		return tablePrinter{w: w}, nil
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return jsonPrinter{enc: enc}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q, use table or json", format)
+	}
+}
+
+type jsonPrinter struct {
+	enc *json.Encoder
+}
+
+func (p jsonPrinter) users(users []modules.User) error {
+	if len(users) == 1 {
+		return p.enc.Encode(users[0])
+	}
+	return p.enc.Encode(users)
+}
+
+func (p jsonPrinter) page(page *modules.PaginatedUsers) error { return p.enc.Encode(page) }
+
+func (p jsonPrinter) auditLogs(logs []modules.AuditLog) error {
+	for _, l := range logs {
+		if err := p.enc.Encode(l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p jsonPrinter) message(msg string) error {
+	return p.enc.Encode(map[string]string{"message": msg})
+}
+
+type tablePrinter struct {
+	w io.Writer
+}
+
+func (p tablePrinter) users(users []modules.User) error {
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tEMAIL\tCREATED")
+	for _, u := range users {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", u.ID, u.Name, u.Email, u.CreatedAt.Format(time.RFC3339))
+	}
+	return tw.Flush()
+}
+
+func (p tablePrinter) page(page *modules.PaginatedUsers) error {
+	if err := p.users(page.Users); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(p.w, "\nshowing %d of %d (offset %d)\n", len(page.Users), page.Total, page.Offset)
+	return err
+}
+
+func (p tablePrinter) auditLogs(logs []modules.AuditLog) error {
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	for _, l := range logs {
+		fmt.Fprintf(tw, "%d\t%s\tuser %d\t%s\n", l.ID, l.CreatedAt.Format(time.RFC3339), l.UserID, l.Action)
+	}
+	return tw.Flush()
+}
+
+func (p tablePrinter) message(msg string) error {
+	_, err := fmt.Fprintln(p.w, msg)
+	return err
+}
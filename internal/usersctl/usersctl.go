@@ -0,0 +1,266 @@
+package usersctl
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"practice4/practice-4/internal/config"
+	"practice4/practice-4/internal/repository"
+	"practice4/practice-4/internal/repository/_postgres"
+	"practice4/practice-4/internal/usecase"
+	"practice4/practice-4/pkg/apperrors"
+	"practice4/practice-4/pkg/client"
+	"practice4/practice-4/pkg/modules"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+const usage = `usage: usersctl [flags] <command> [args]
+
+Talks to a running server when -server is set, otherwise to the database
+configured through the usual DB_* variables or flags.
+
+commands:
+  list [-limit N] [-offset N]
+  get ID
+  create -name NAME -email EMAIL [-audit]
+  update ID -name NAME -email EMAIL
+  delete ID
+  restore ID
+  audit [-user ID] [-n N] [-f] [-interval D]
+
+flags:
+`
+
+type cli struct {
+	uc  usecase.UserUsecase
+	out printer
+}
+
+func Run(args []string) error {
+	_ = godotenv.Load()
+
+	fs := flag.NewFlagSet("usersctl", flag.ContinueOnError)
+	server := fs.String("server", os.Getenv("USERSCTL_SERVER"), "base URL of a running API server")
+	output := fs.String("output", "table", "output format: table or json")
+	timeout := fs.Duration("timeout", 30*time.Second, "HTTP request timeout")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+
+	cfg, err := config.Load(fs, args, 0)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("missing command")
+	}
+
+	out, err := newPrinter(*output, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var uc usecase.UserUsecase
+	if *server != "" {
+		if err := cfg.Validate(config.Auth); err != nil {
+			return err
+		}
+		c, err := client.New(*server, client.WithAPIKey(cfg.APIKey), client.WithTimeout(*timeout))
+		if err != nil {
+			return err
+		}
+		uc = &httpUsecase{c: c}
+	} else {
+		if err := cfg.Validate(config.Postgres); err != nil {
+			return err
+		}
+		db, err := _postgres.NewPGXDialect(ctx, &cfg.Postgres)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		repos := repository.NewRepositories(db, &cfg.Postgres)
+		// A single command gains nothing from caching counts.
+		uc = usecase.NewUserUsecase(repos.Users, repos.Tx, cfg.Count, nil)
+	}
+
+	c := &cli{uc: uc, out: out}
+	return describe(c.run(ctx, fs.Arg(0), fs.Args()[1:]))
+}
+
+// describe replaces the status-code texts of apperrors with readable ones.
+func describe(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, apperrors.ErrNotFound):
+		return errors.New("not found")
+	case errors.Is(err, apperrors.ErrValidation):
+		return errors.New("invalid input")
+	case errors.Is(err, apperrors.ErrConflict):
+		return errors.New("conflict: user already exists")
+	case errors.Is(err, apperrors.ErrTimeout):
+		return errors.New("request timed out")
+	case errors.Is(err, apperrors.ErrTransient):
+		return errors.New("conflicting concurrent change, try again")
+	case errors.Is(err, client.ErrUnauthorized):
+		return errors.New("unauthorized: check API_KEY")
+	default:
+		return err
+	}
+}
+
+func (c *cli) run(ctx context.Context, cmd string, args []string) error {
+	switch cmd {
+	case "list":
+		return c.list(ctx, args)
+	case "get":
+		return c.withID(args, func(id int64) error {
+			user, err := c.uc.GetByID(ctx, id)
+			if err != nil {
+				return err
+			}
+			return c.out.users([]modules.User{*user})
+		})
+	case "create":
+		return c.create(ctx, args)
+	case "update":
+		return c.update(ctx, args)
+	case "delete":
+		return c.withID(args, func(id int64) error {
+			if err := c.uc.Delete(ctx, id); err != nil {
+				return err
+			}
+			return c.out.message(fmt.Sprintf("user %d deleted", id))
+		})
+	case "restore":
+		return c.withID(args, func(id int64) error {
+			if err := c.uc.Restore(ctx, id); err != nil {
+				return err
+			}
+			return c.out.message(fmt.Sprintf("user %d restored", id))
+		})
+	case "audit":
+		return c.audit(ctx, args)
+	default:
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+}
+
+func (c *cli) withID(args []string, fn func(id int64) error) error {
+	if len(args) != 1 {
+		return errors.New("expected exactly one user ID")
+	}
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil || id <= 0 {
+		return fmt.Errorf("invalid user ID %q", args[0])
+	}
+	return fn(id)
+}
+
+func (c *cli) list(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	limit := fs.Int64("limit", 10, "page size")
+	offset := fs.Int64("offset", 0, "number of users to skip")
+	name := fs.String("name", "", "only users whose name contains this")
+	email := fs.String("email", "", "only users whose email contains this")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	page, err := c.uc.GetAll(ctx, modules.UserFilter{Name: *name, Email: *email}, *limit, *offset)
+	if err != nil {
+		return err
+	}
+	return c.out.page(page)
+}
+
+func (c *cli) create(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("create", flag.ContinueOnError)
+	name := fs.String("name", "", "user name")
+	email := fs.String("email", "", "user email")
+	audit := fs.Bool("audit", false, "also write an audit log entry")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	user := &modules.User{Name: *name, Email: *email}
+	create := c.uc.Create
+	if *audit {
+		create = c.uc.CreateUserWithAudit
+	}
+	id, err := create(ctx, user)
+	if err != nil {
+		return err
+	}
+	return c.out.message(fmt.Sprintf("user %d created", id))
+}
+
+func (c *cli) update(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("expected a user ID")
+	}
+	return c.withID(args[:1], func(id int64) error {
+		fs := flag.NewFlagSet("update", flag.ContinueOnError)
+		name := fs.String("name", "", "user name")
+		email := fs.String("email", "", "user email")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if err := c.uc.Update(ctx, &modules.User{ID: id, Name: *name, Email: *email}); err != nil {
+			return err
+		}
+		return c.out.message(fmt.Sprintf("user %d updated", id))
+	})
+}
+
+func (c *cli) audit(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+	userID := fs.Int64("user", 0, "only entries for this user")
+	n := fs.Int64("n", 20, "number of entries to show")
+	follow := fs.Bool("f", false, "keep polling for new entries")
+	interval := fs.Duration("interval", 2*time.Second, "poll interval with -f")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	filter := modules.AuditLogFilter{UserID: *userID, Limit: *n, Tail: true}
+	for first := true; ; first = false {
+		logs, err := c.uc.ListAuditLogs(ctx, filter)
+		if err != nil {
+			return err
+		}
+		if first || len(logs) > 0 {
+			if err := c.out.auditLogs(logs); err != nil {
+				return err
+			}
+		}
+		if !*follow {
+			return nil
+		}
+		// Follow in commit order: an entry can commit after one with a
+		// higher ID has been printed, and would be skipped by ID.
+		for _, l := range logs {
+			filter.AfterSeq = max(filter.AfterSeq, l.Seq)
+		}
+		filter.Limit = 500
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(*interval):
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package usersctl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"practice4/practice-4/internal/usecase"
+	"practice4/practice-4/pkg/apperrors"
+	"practice4/practice-4/pkg/modules"
+)
+
+// fakeUsers is a UserUsecase over an in-memory audit log. Before each
+// ListAuditLogs call after the first, the next of commits is appended, as if
+// those entries had committed between polls.
+type fakeUsers struct {
+	usecase.UserUsecase
+
+	logs     []modules.AuditLog
+	commits  [][]modules.AuditLog
+	filters  []modules.AuditLogFilter
+	stop     func()
+	restored []int64
+}
+
+func (f *fakeUsers) ListAuditLogs(_ context.Context, filter modules.AuditLogFilter) ([]modules.AuditLog, error) {
+	if len(f.filters) > 0 {
+		if len(f.commits) == 0 {
+			f.stop()
+			return nil, nil
+		}
+		f.logs, f.commits = append(f.logs, f.commits[0]...), f.commits[1:]
+	}
+	f.filters = append(f.filters, filter)
+
+	var out []modules.AuditLog
+	for _, l := range f.logs {
+		if filter.AfterSeq > 0 && l.Seq > filter.AfterSeq || filter.AfterSeq == 0 && l.ID > filter.AfterID {
+			out = append(out, l)
+		}
+	}
+	if filter.AfterSeq > 0 {
+		sort.Slice(out, func(i, j int) bool { return out[i].Seq < out[j].Seq })
+	} else {
+		sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+		if filter.Tail && filter.AfterID == 0 && int64(len(out)) > filter.Limit {
+			out = out[int64(len(out))-filter.Limit:]
+		}
+	}
+	return out[:min(int64(len(out)), filter.Limit)], nil
+}
+
+func (f *fakeUsers) Restore(_ context.Context, id int64) error {
+	if id != 1 {
+		return apperrors.ErrNotFound
+	}
+	f.restored = append(f.restored, id)
+	return nil
+}
+
+func newCLI(t *testing.T, uc usecase.UserUsecase, format string) (*cli, *bytes.Buffer) {
+	t.Helper()
+	var buf bytes.Buffer
+	out, err := newPrinter(format, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &cli{uc: uc, out: out}, &buf
+}
+
+// printedIDs decodes the JSON printer's audit log entries.
+func printedIDs(t *testing.T, r io.Reader) []int64 {
+	t.Helper()
+	var ids []int64
+	dec := json.NewDecoder(r)
+	for {
+		var l modules.AuditLog
+		if err := dec.Decode(&l); err == io.EOF {
+			return ids
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, l.ID)
+	}
+}
+
+func TestAuditTail(t *testing.T) {
+	uc := &fakeUsers{logs: []modules.AuditLog{{ID: 1, Seq: 1}, {ID: 2, Seq: 2}, {ID: 3, Seq: 3}}}
+	c, out := newCLI(t, uc, "json")
+	if err := c.run(context.Background(), "audit", []string{"-n", "2", "-user", "7"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := printedIDs(t, out); !slices.Equal(got, []int64{2, 3}) {
+		t.Errorf("printed %v, want the last two entries [2 3]", got)
+	}
+	if f := uc.filters[0]; f.UserID != 7 || f.Limit != 2 || !f.Tail {
+		t.Errorf("filter = %+v, want user 7, limit 2, tail", f)
+	}
+}
+
+func TestAuditFollowsCommitOrder(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	uc := &fakeUsers{
+		logs: []modules.AuditLog{{ID: 1, Seq: 1}, {ID: 2, Seq: 2}},
+		commits: [][]modules.AuditLog{
+			{{ID: 4, Seq: 3}},
+			// Entry 3 was inserted before entry 4 but commits after it
+			// was printed.
+			{{ID: 3, Seq: 4}},
+			{},
+		},
+		stop: cancel,
+	}
+	c, out := newCLI(t, uc, "json")
+	if err := c.run(ctx, "audit", []string{"-f", "-interval", "1ms"}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []int64{1, 2, 4, 3}
+	if got := printedIDs(t, out); !slices.Equal(got, want) {
+		t.Errorf("printed %v, want %v", got, want)
+	}
+	for i, wantSeq := range []int64{0, 2, 3, 4} {
+		if got := uc.filters[i].AfterSeq; got != wantSeq {
+			t.Errorf("poll %d: AfterSeq = %d, want %d", i, got, wantSeq)
+		}
+	}
+}
+
+func TestRestore(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		args []string
+		out  string
+		err  string
+	}{
+		{"restored", []string{"1"}, "user 1 restored\n", ""},
+		{"not found", []string{"2"}, "", "not found"},
+		{"invalid ID", []string{"abc"}, "", `invalid user ID "abc"`},
+		{"missing ID", nil, "", "expected exactly one user ID"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			uc := &fakeUsers{}
+			c, out := newCLI(t, uc, "table")
+			err := describe(c.run(context.Background(), "restore", tc.args))
+			if tc.err != "" {
+				if err == nil || err.Error() != tc.err {
+					t.Fatalf("err = %v, want %q", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tc.out || len(uc.restored) != 1 {
+				t.Errorf("printed %q and restored %v, want %q", out, uc.restored, tc.out)
+			}
+		})
+	}
+}
+
+func TestTablePrinter(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	var buf bytes.Buffer
+	p := tablePrinter{w: &buf}
+
+	p.auditLogs([]modules.AuditLog{{ID: 1, UserID: 7, Action: "create", CreatedAt: created}, {ID: 12, UserID: 8, Action: "delete", CreatedAt: created}})
+	want := "1   2024-01-02T03:04:05Z  user 7  create\n" +
+		"12  2024-01-02T03:04:05Z  user 8  delete\n"
+	if buf.String() != want {
+		t.Errorf("audit logs:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	p.page(&modules.PaginatedUsers{Users: []modules.User{{ID: 1, Name: "Alice", Email: "alice@example.com", CreatedAt: created}}, Total: 3, Offset: 1})
+	want = "ID  NAME   EMAIL              CREATED\n" +
+		"1   Alice  alice@example.com  2024-01-02T03:04:05Z\n" +
+		"\nshowing 1 of 3 (offset 1)\n"
+	if buf.String() != want {
+		t.Errorf("page:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestJSONPrinter(t *testing.T) {
+	var buf bytes.Buffer
+	p, err := newPrinter("json", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.users([]modules.User{{ID: 1, Name: "Alice"}})
+	p.message("done")
+
+	dec := json.NewDecoder(&buf)
+	var user modules.User
+	var msg map[string]string
+	if err := dec.Decode(&user); err != nil || user.Name != "Alice" {
+		t.Errorf("single user printed as %+v, %v; want an object", user, err)
+	}
+	if err := dec.Decode(&msg); err != nil || msg["message"] != "done" {
+		t.Errorf("message printed as %v, %v", msg, err)
+	}
+}
+
+func TestNewPrinterRejectsUnknownFormat(t *testing.T) {
+	if _, err := newPrinter("yaml", io.Discard); err == nil || !strings.Contains(err.Error(), `unknown output format "yaml"`) {
+		t.Errorf("newPrinter = %v, want an unknown format error", err)
+	}
+}
@@ -0,0 +1,153 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"practice4/practice-4/internal/repository"
+	"practice4/practice-4/pkg/modules"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Fanout is an events.EventPublisher that queues each event for the webhook
+// subscriptions to its type.
+type Fanout struct {
+	repo repository.WebhookRepository
+}
+
+func NewFanout(repo repository.WebhookRepository) *Fanout {
+	return &Fanout{repo: repo}
+}
+
+func (f *Fanout) Publish(ctx context.Context, event modules.Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = f.repo.EnqueueDeliveries(ctx, event, body)
+	return err
+}
+
+// Dispatcher sends queued deliveries to their subscriptions, retrying
+// failures with exponential backoff until cfg.MaxAttempts, after which a
+// delivery is dead and only comes back through a replay.
+type Dispatcher struct {
+	repo   repository.WebhookRepository
+	cfg    modules.WebhooksConfig
+	client *http.Client
+}
+
+// leaseMargin is how long a claimed delivery stays leased beyond the send
+// timeout, to cover recording the outcome.
+const leaseMargin = 30 * time.Second
+
+func NewDispatcher(repo repository.WebhookRepository, cfg modules.WebhooksConfig) *Dispatcher {
+	return &Dispatcher{repo: repo, cfg: cfg, client: &http.Client{Timeout: cfg.Timeout}}
+}
+
+// Run polls for due deliveries until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	for {
+		n, err := d.dispatch(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Printf("webhook dispatcher: %v", err)
+		}
+		if err == nil && n == d.cfg.BatchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(d.cfg.PollInterval):
+		}
+	}
+}
+
+// dispatch claims a batch of due deliveries and sends them concurrently, so
+// a slow receiver only holds up its own deliveries. It returns how many were
+// claimed.
+func (d *Dispatcher) dispatch(ctx context.Context) (int, error) {
+	due, err := d.repo.ClaimDue(ctx, d.cfg.BatchSize, d.cfg.Timeout+leaseMargin)
+	if err != nil {
+		return 0, err
+	}
+	var wg sync.WaitGroup
+	for i := range due {
+		wg.Add(1)
+		go func(due *modules.DueDelivery) {
+			defer wg.Done()
+			d.deliver(ctx, due)
+			if err := d.repo.RecordAttempt(ctx, &due.WebhookDelivery); err != nil {
+				log.Printf("webhook delivery %d: record attempt: %v", due.ID, err)
+			}
+		}(&due[i])
+	}
+	wg.Wait()
+	return len(due), nil
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, delivery *modules.DueDelivery) {
+	code, err := d.send(ctx, delivery)
+	delivery.Attempts++
+	delivery.LastStatusCode = code
+	if err == nil {
+		delivery.Status = modules.DeliverySucceeded
+		delivery.LastError = ""
+		return
+	}
+
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= d.cfg.MaxAttempts {
+		delivery.Status = modules.DeliveryDead
+		log.Printf("webhook delivery %d to subscription %d is dead after %d attempts: %v",
+			delivery.ID, delivery.SubscriptionID, delivery.Attempts, err)
+		return
+	}
+	delivery.NextAttemptAt = time.Now().Add(d.backoff(delivery.Attempts))
+}
+
+// backoff doubles from RetryInitial per attempt up to RetryMax, with jitter
+// in [wait/2, wait] so failing receivers are not hit in lockstep.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.cfg.RetryMax
+	if shift := attempts - 1; shift < 32 {
+		wait = min(d.cfg.RetryInitial<<shift, d.cfg.RetryMax)
+	}
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+}
+
+// send posts the delivery and returns the response status, or 0 when no
+// response arrived.
+func (d *Dispatcher) send(ctx context.Context, delivery *modules.DueDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "practice4-webhooks")
+	req.Header.Set("X-Webhook-ID", strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set("X-Event-ID", strconv.FormatInt(delivery.EventID, 10))
+	req.Header.Set("X-Event-Type", delivery.EventType)
+	req.Header.Set(SignatureHeader, Sign(delivery.Secret, time.Now(), delivery.Body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
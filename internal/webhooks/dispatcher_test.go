@@ -0,0 +1,233 @@
+package webhooks
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"practice4/practice-4/internal/repository"
+	"practice4/practice-4/pkg/apperrors"
+	"practice4/practice-4/pkg/modules"
+)
+
+const testSecret = "0123456789abcdef"
+
+// memDeliveries is an in-memory WebhookRepository holding deliveries for a
+// single subscription.
+type memDeliveries struct {
+	repository.WebhookRepository
+
+	mu         sync.Mutex
+	url        string
+	deliveries []modules.WebhookDelivery
+}
+
+func (m *memDeliveries) add(eventID int64, replayOf *int64) int64 {
+	id := int64(len(m.deliveries) + 1)
+	m.deliveries = append(m.deliveries, modules.WebhookDelivery{
+		ID: id, SubscriptionID: 1, EventID: eventID, EventType: modules.EventUserCreated,
+		Body: []byte(`{"id":` + strconv.FormatInt(eventID, 10) + `}`), Status: modules.DeliveryPending,
+		NextAttemptAt: time.Now(), ReplayOf: replayOf,
+	})
+	return id
+}
+
+func (m *memDeliveries) get(id int64) modules.WebhookDelivery {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.deliveries[id-1]
+}
+
+// makeDue pretends every backoff has elapsed.
+func (m *memDeliveries) makeDue() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.deliveries {
+		m.deliveries[i].NextAttemptAt = time.Now()
+	}
+}
+
+func (m *memDeliveries) ClaimDue(_ context.Context, limit int, lease time.Duration) ([]modules.DueDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var due []modules.DueDelivery
+	for i := range m.deliveries {
+		d := &m.deliveries[i]
+		if len(due) == limit || d.Status != modules.DeliveryPending || d.NextAttemptAt.After(time.Now()) {
+			continue
+		}
+		d.NextAttemptAt = time.Now().Add(lease)
+		due = append(due, modules.DueDelivery{WebhookDelivery: *d, URL: m.url, Secret: testSecret})
+	}
+	return due, nil
+}
+
+func (m *memDeliveries) RecordAttempt(_ context.Context, d *modules.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deliveries[d.ID-1] = *d
+	return nil
+}
+
+func (m *memDeliveries) ReplayDelivery(_ context.Context, _, id int64) (*modules.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if id < 1 || id > int64(len(m.deliveries)) {
+		return nil, apperrors.ErrNotFound
+	}
+	replay := m.add(m.deliveries[id-1].EventID, &id)
+	d := m.deliveries[replay-1]
+	return &d, nil
+}
+
+// receiver is an httptest receiver that verifies signatures and answers
+// with the current status.
+type receiver struct {
+	*httptest.Server
+	status   atomic.Int32
+	received atomic.Int32
+}
+
+func newReceiver(t *testing.T, status int) *receiver {
+	t.Helper()
+	rc := &receiver{}
+	rc.status.Store(int32(status))
+	rc.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := Verify(testSecret, r.Header.Get(SignatureHeader), body, time.Minute); err != nil {
+			t.Errorf("receiver: %v", err)
+		}
+		if r.Header.Get("X-Event-Type") != modules.EventUserCreated {
+			t.Errorf("receiver: X-Event-Type = %q", r.Header.Get("X-Event-Type"))
+		}
+		rc.received.Add(1)
+		w.WriteHeader(int(rc.status.Load()))
+	}))
+	t.Cleanup(rc.Close)
+	return rc
+}
+
+func newDispatcher(repo repository.WebhookRepository, maxAttempts int) *Dispatcher {
+	return NewDispatcher(repo, modules.WebhooksConfig{
+		MaxAttempts:  maxAttempts,
+		RetryInitial: time.Second,
+		RetryMax:     time.Minute,
+		Timeout:      5 * time.Second,
+		BatchSize:    10,
+	})
+}
+
+func TestDispatcherDelivers(t *testing.T) {
+	rc := newReceiver(t, http.StatusNoContent)
+	repo := &memDeliveries{url: rc.URL}
+	repo.add(1, nil)
+	repo.add(2, nil)
+
+	n, err := newDispatcher(repo, 3).dispatch(context.Background())
+	if err != nil || n != 2 {
+		t.Fatalf("dispatch = %d, %v; want 2, nil", n, err)
+	}
+	for id := int64(1); id <= 2; id++ {
+		d := repo.get(id)
+		if d.Status != modules.DeliverySucceeded || d.Attempts != 1 || d.LastStatusCode != http.StatusNoContent {
+			t.Errorf("delivery %d = %s after %d attempts (status code %d)", id, d.Status, d.Attempts, d.LastStatusCode)
+		}
+	}
+	if n, _ := newDispatcher(repo, 3).dispatch(context.Background()); n != 0 {
+		t.Errorf("second dispatch claimed %d deliveries, want 0", n)
+	}
+}
+
+func TestDispatcherRetriesThenDeadLetters(t *testing.T) {
+	rc := newReceiver(t, http.StatusInternalServerError)
+	repo := &memDeliveries{url: rc.URL}
+	repo.add(1, nil)
+	d := newDispatcher(repo, 3)
+	ctx := context.Background()
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		start := time.Now()
+		if n, err := d.dispatch(ctx); n != 1 || err != nil {
+			t.Fatalf("attempt %d: dispatch = %d, %v", attempt, n, err)
+		}
+		got := repo.get(1)
+		if got.Attempts != attempt || got.LastStatusCode != http.StatusInternalServerError || got.LastError == "" {
+			t.Fatalf("attempt %d: delivery = %+v", attempt, got)
+		}
+		if attempt < 3 {
+			if got.Status != modules.DeliveryPending {
+				t.Fatalf("attempt %d: status = %s, want pending", attempt, got.Status)
+			}
+			if n, _ := d.dispatch(ctx); n != 0 {
+				t.Fatalf("attempt %d: retried before its backoff elapsed", attempt)
+			}
+			wait := got.NextAttemptAt.Sub(start)
+			if maxWait := time.Second << (attempt - 1); wait < maxWait/2 || wait > maxWait+time.Second {
+				t.Errorf("attempt %d: next attempt in %v, want within [%v, %v]", attempt, wait, maxWait/2, maxWait)
+			}
+			repo.makeDue()
+		}
+	}
+	if got := repo.get(1); got.Status != modules.DeliveryDead {
+		t.Fatalf("status after max attempts = %s, want dead", got.Status)
+	}
+	repo.makeDue()
+	if n, _ := d.dispatch(ctx); n != 0 {
+		t.Errorf("dead delivery was claimed again")
+	}
+	if got := rc.received.Load(); got != 3 {
+		t.Errorf("receiver got %d requests, want 3", got)
+	}
+}
+
+func TestDispatcherReplaysDeadDelivery(t *testing.T) {
+	rc := newReceiver(t, http.StatusBadGateway)
+	repo := &memDeliveries{url: rc.URL}
+	repo.add(7, nil)
+	d := newDispatcher(repo, 1)
+	ctx := context.Background()
+
+	d.dispatch(ctx)
+	if got := repo.get(1); got.Status != modules.DeliveryDead {
+		t.Fatalf("status = %s, want dead", got.Status)
+	}
+
+	rc.status.Store(http.StatusOK)
+	replay, err := repo.ReplayDelivery(ctx, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := d.dispatch(ctx); n != 1 || err != nil {
+		t.Fatalf("dispatch = %d, %v; want 1, nil", n, err)
+	}
+	got := repo.get(replay.ID)
+	if got.Status != modules.DeliverySucceeded || got.EventID != 7 || got.ReplayOf == nil || *got.ReplayOf != 1 {
+		t.Errorf("replay = %+v", got)
+	}
+	if orig := repo.get(1); orig.Status != modules.DeliveryDead || orig.Attempts != 1 {
+		t.Errorf("original changed to %s after %d attempts", orig.Status, orig.Attempts)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	d := newDispatcher(nil, 10)
+	for attempts, want := range map[int]time.Duration{
+		1:  time.Second,
+		2:  2 * time.Second,
+		4:  8 * time.Second,
+		7:  time.Minute,
+		40: time.Minute,
+	} {
+		for range 20 {
+			if got := d.backoff(attempts); got < want/2 || got > want {
+				t.Errorf("backoff(%d) = %v, want within [%v, %v]", attempts, got, want/2, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package webhooks
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	const secret = "0123456789abcdef"
+	body := []byte(`{"id":1,"type":"UserCreated"}`)
+	now := time.Now()
+	valid := Sign(secret, now, body)
+
+	tests := []struct {
+		name      string
+		secret    string
+		header    string
+		body      []byte
+		tolerance time.Duration
+		wantErr   bool
+	}{
+		{"valid", secret, valid, body, 5 * time.Minute, false},
+		{"no tolerance", secret, Sign(secret, now.Add(-24*time.Hour), body), body, 0, false},
+		{"tampered body", secret, valid, []byte(`{"id":2,"type":"UserCreated"}`), 5 * time.Minute, true},
+		{"wrong secret", "fedcba9876543210", valid, body, 5 * time.Minute, true},
+		{"expired", secret, Sign(secret, now.Add(-10*time.Minute), body), body, 5 * time.Minute, true},
+		{"from the future", secret, Sign(secret, now.Add(10*time.Minute), body), body, 5 * time.Minute, true},
+		{"timestamp changed", secret, strings.Replace(valid, "t=", "t=1", 1), body, 0, true},
+		{"timestamp not a number", secret, "t=abc," + valid[strings.Index(valid, "v1="):], body, 0, true},
+		{"no signature", secret, "t=" + valid[2:strings.Index(valid, ",")], body, 0, true},
+		{"empty header", secret, "", body, 0, true},
+		{"one of several signatures", secret, valid + ",v1=00ff", body, 5 * time.Minute, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.header, tt.body, tt.tolerance)
+			if tt.wantErr && !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("Verify = %v, want ErrInvalidSignature", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Verify = %v, want nil", err)
+			}
+		})
+	}
+}
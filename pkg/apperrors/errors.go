@@ -3,8 +3,12 @@ package apperrors
 import "errors"
 
 var (
-	ErrNotFound = errors.New("404")
-	ErrConflict = errors.New("already exists")
-	ErrInternal = errors.New("500")
+	ErrNotFound   = errors.New("404")
+	ErrConflict   = errors.New("already exists")
+	ErrInternal   = errors.New("500")
 	ErrValidation = errors.New("400")
-)
\ No newline at end of file
+	ErrTimeout    = errors.New("504")
+	// ErrTransient marks a failure that retrying the request may resolve,
+	// such as losing a serialization conflict or deadlock.
+	ErrTransient = errors.New("503")
+)
@@ -0,0 +1,260 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"practice4/practice-4/pkg/modules"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client is a typed client for the users API. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+	timeout    time.Duration
+	retry      RetryPolicy
+}
+
+// RetryPolicy controls how failed requests are retried. Idempotent requests
+// are retried on network errors, 5xx and 429 responses; POST requests only on
+// 429, since the server did not process them.
+type RetryPolicy struct {
+	MaxAttempts     int
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+}
+
+type Option func(*Client)
+
+func WithAPIKey(key string) Option {
+	return func(c *Client) { c.apiKey = key }
+}
+
+// WithTimeout limits each attempt of a request. It applies to a copy of the
+// client given to WithHTTPClient, which is left unchanged.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) { c.timeout = d }
+}
+
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+func WithRetry(p RetryPolicy) Option {
+	return func(c *Client) { c.retry = p }
+}
+
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("client: invalid base URL %q", baseURL)
+	}
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		retry: RetryPolicy{
+			MaxAttempts:     3,
+			InitialInterval: 200 * time.Millisecond,
+			MaxInterval:     5 * time.Second,
+		},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.timeout > 0 {
+		hc := *c.httpClient
+		hc.Timeout = c.timeout
+		c.httpClient = &hc
+	}
+	return c, nil
+}
+
+func (c *Client) ListUsers(ctx context.Context, limit, offset int64) (*modules.PaginatedUsers, error) {
+	return c.ListUsersMatching(ctx, modules.UserFilter{}, limit, offset)
+}
+
+// ListUsersMatching lists users whose name and email contain the filter's
+// values, ignoring case.
+func (c *Client) ListUsersMatching(ctx context.Context, filter modules.UserFilter, limit, offset int64) (*modules.PaginatedUsers, error) {
+	q := url.Values{"limit": {strconv.FormatInt(limit, 10)}, "offset": {strconv.FormatInt(offset, 10)}}
+	if filter.Name != "" {
+		q.Set("name", filter.Name)
+	}
+	if filter.Email != "" {
+		q.Set("email", filter.Email)
+	}
+	if filter.AfterID > 0 {
+		q.Set("after_id", strconv.FormatInt(filter.AfterID, 10))
+	}
+	var page modules.PaginatedUsers
+	if err := c.do(ctx, http.MethodGet, "/users?"+q.Encode(), nil, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// SearchUsers finds up to limit users by partial or misspelled name or
+// email, best match first.
+func (c *Client) SearchUsers(ctx context.Context, query string, limit int64) (*modules.UserSearchResults, error) {
+	q := url.Values{"q": {query}, "limit": {strconv.FormatInt(limit, 10)}}
+	var results modules.UserSearchResults
+	if err := c.do(ctx, http.MethodGet, "/users/search?"+q.Encode(), nil, &results); err != nil {
+		return nil, err
+	}
+	return &results, nil
+}
+
+func (c *Client) GetUser(ctx context.Context, id int64) (*modules.User, error) {
+	var user modules.User
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/users/%d", id), nil, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (c *Client) CreateUser(ctx context.Context, input modules.UserInput) (int64, error) {
+	return c.create(ctx, "/users", input)
+}
+
+// CreateUserWithAudit creates a user and its audit log entry in one transaction.
+func (c *Client) CreateUserWithAudit(ctx context.Context, input modules.UserInput) (int64, error) {
+	return c.create(ctx, "/users/audit", input)
+}
+
+func (c *Client) create(ctx context.Context, path string, input modules.UserInput) (int64, error) {
+	var resp struct {
+		ID int64 `json:"id"`
+	}
+	if err := c.do(ctx, http.MethodPost, path, input, &resp); err != nil {
+		return 0, err
+	}
+	return resp.ID, nil
+}
+
+func (c *Client) UpdateUser(ctx context.Context, id int64, input modules.UserInput) error {
+	return c.do(ctx, http.MethodPut, fmt.Sprintf("/users/%d", id), input, nil)
+}
+
+func (c *Client) DeleteUser(ctx context.Context, id int64) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/users/%d", id), nil, nil)
+}
+
+func (c *Client) RestoreUser(ctx context.Context, id int64) error {
+	return c.do(ctx, http.MethodPost, fmt.Sprintf("/users/%d/restore", id), nil, nil)
+}
+
+func (c *Client) ListAuditLogs(ctx context.Context, filter modules.AuditLogFilter) ([]modules.AuditLog, error) {
+	q := url.Values{
+		"user_id":   {strconv.FormatInt(filter.UserID, 10)},
+		"after_id":  {strconv.FormatInt(filter.AfterID, 10)},
+		"after_seq": {strconv.FormatInt(filter.AfterSeq, 10)},
+		"limit":     {strconv.FormatInt(filter.Limit, 10)},
+		"tail":      {strconv.FormatBool(filter.Tail)},
+	}
+	var logs []modules.AuditLog
+	if err := c.do(ctx, http.MethodGet, "/audit-logs?"+q.Encode(), nil, &logs); err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("client: encode request: %w", err)
+		}
+	}
+
+	interval := c.retry.InitialInterval
+	for attempt := 1; ; attempt++ {
+		resp, err := c.send(ctx, method, path, payload)
+		retryable, wait := c.shouldRetry(method, resp, err)
+		if !retryable || attempt >= c.retry.MaxAttempts {
+			if err != nil {
+				return fmt.Errorf("client: %s %s: %w", method, path, err)
+			}
+			return decodeResponse(resp, out)
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		if wait == 0 {
+			wait = interval/2 + time.Duration(rand.Int63n(int64(interval/2)+1))
+			interval *= 2
+			if interval > c.retry.MaxInterval {
+				interval = c.retry.MaxInterval
+			}
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, path string, payload []byte) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-KEY", c.apiKey)
+	}
+	return c.httpClient.Do(req)
+}
+
+// shouldRetry reports whether a request may be retried and, for 429
+// responses carrying Retry-After, how long to wait first, at most the
+// policy's MaxInterval.
+func (c *Client) shouldRetry(method string, resp *http.Response, err error) (bool, time.Duration) {
+	if err != nil {
+		cancelled := errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+		return method != http.MethodPost && !cancelled, 0
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs >= 0 {
+			return true, min(time.Duration(secs)*time.Second, c.retry.MaxInterval)
+		}
+		return true, 0
+	}
+	return method != http.MethodPost && resp.StatusCode >= 500, 0
+}
+
+func decodeResponse(resp *http.Response, out any) error {
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return newAPIError(resp)
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("client: decode response: %w", err)
+	}
+	return nil
+}
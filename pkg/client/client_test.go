@@ -0,0 +1,238 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"practice4/practice-4/pkg/apperrors"
+	"practice4/practice-4/pkg/client"
+	"practice4/practice-4/pkg/modules"
+)
+
+var fastRetry = client.RetryPolicy{MaxAttempts: 3, InitialInterval: time.Millisecond, MaxInterval: 5 * time.Millisecond}
+
+// failing returns a server answering every request with status and counting
+// the requests.
+func failing(t *testing.T, status int, header http.Header) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+	var n atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n.Add(1)
+		for k, v := range header {
+			w.Header()[k] = v
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": http.StatusText(status)})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &n
+}
+
+func newClient(t *testing.T, url string, opts ...client.Option) *client.Client {
+	t.Helper()
+	c, err := client.New(url, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestRetries(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		status   int
+		post     bool
+		attempts int64
+	}{
+		{"GET on 500", http.StatusInternalServerError, false, 3},
+		{"GET on 503", http.StatusServiceUnavailable, false, 3},
+		{"GET on 429", http.StatusTooManyRequests, false, 3},
+		{"GET on 404", http.StatusNotFound, false, 1},
+		{"POST on 500", http.StatusInternalServerError, true, 1},
+		{"POST on 503", http.StatusServiceUnavailable, true, 1},
+		{"POST on 429", http.StatusTooManyRequests, true, 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv, n := failing(t, tc.status, nil)
+			c := newClient(t, srv.URL, client.WithRetry(fastRetry))
+
+			var err error
+			if tc.post {
+				_, err = c.CreateUser(context.Background(), modules.UserInput{Name: "Alice", Email: "alice@example.com"})
+			} else {
+				_, err = c.GetUser(context.Background(), 1)
+			}
+			var apiErr *client.APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != tc.status {
+				t.Errorf("err = %v, want an APIError with status %d", err, tc.status)
+			}
+			if got := n.Load(); got != tc.attempts {
+				t.Errorf("server saw %d requests, want %d", got, tc.attempts)
+			}
+		})
+	}
+}
+
+func TestRetrySucceeds(t *testing.T) {
+	var n atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if n.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		json.NewEncoder(w).Encode(modules.User{ID: 1, Name: "Alice"})
+	}))
+	defer srv.Close()
+
+	u, err := newClient(t, srv.URL, client.WithRetry(fastRetry)).GetUser(context.Background(), 1)
+	if err != nil || u.Name != "Alice" {
+		t.Fatalf("GetUser = %+v, %v; want Alice on the third attempt", u, err)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		maxInterval time.Duration
+		min, max    time.Duration
+	}{
+		{"honoured", 5 * time.Second, time.Second, 2 * time.Second},
+		{"clamped to the maximum backoff", 50 * time.Millisecond, 50 * time.Millisecond, 500 * time.Millisecond},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv, n := failing(t, http.StatusTooManyRequests, http.Header{"Retry-After": {"1"}})
+			policy := client.RetryPolicy{MaxAttempts: 2, InitialInterval: time.Millisecond, MaxInterval: tc.maxInterval}
+			c := newClient(t, srv.URL, client.WithRetry(policy))
+
+			start := time.Now()
+			_, err := c.GetUser(context.Background(), 1)
+			elapsed := time.Since(start)
+			if !errors.Is(err, client.ErrRateLimited) {
+				t.Errorf("err = %v, want ErrRateLimited", err)
+			}
+			if n.Load() != 2 {
+				t.Errorf("server saw %d requests, want 2", n.Load())
+			}
+			if elapsed < tc.min || elapsed > tc.max {
+				t.Errorf("waited %v, want between %v and %v", elapsed, tc.min, tc.max)
+			}
+		})
+	}
+}
+
+func TestRetryStopsOnCancel(t *testing.T) {
+	srv, n := failing(t, http.StatusServiceUnavailable, nil)
+	policy := client.RetryPolicy{MaxAttempts: 10, InitialInterval: time.Second, MaxInterval: time.Second}
+	c := newClient(t, srv.URL, client.WithRetry(policy))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.GetUser(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if n.Load() != 1 {
+		t.Errorf("server saw %d requests, want 1", n.Load())
+	}
+}
+
+func TestWithTimeoutCopiesHTTPClient(t *testing.T) {
+	hc := &http.Client{Timeout: time.Minute}
+	newClient(t, "http://example.com", client.WithHTTPClient(hc), client.WithTimeout(time.Second))
+	if hc.Timeout != time.Minute {
+		t.Errorf("caller's client timeout = %v, want it left at 1m", hc.Timeout)
+	}
+}
+
+func TestWithTimeoutLimitsRequests(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	c := newClient(t, srv.URL, client.WithHTTPClient(&http.Client{}), client.WithTimeout(20*time.Millisecond),
+		client.WithRetry(client.RetryPolicy{MaxAttempts: 1}))
+	if _, err := c.GetUser(context.Background(), 1); err == nil {
+		t.Fatal("GetUser = nil, want a timeout")
+	}
+}
+
+func TestUsersIteratorFollowsClampedLimit(t *testing.T) {
+	const total, maxLimit = 250, 100
+	var requests atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		limit, _ := strconv.ParseInt(r.URL.Query().Get("limit"), 10, 64)
+		offset, _ := strconv.ParseInt(r.URL.Query().Get("offset"), 10, 64)
+		limit = min(limit, maxLimit)
+		page := modules.PaginatedUsers{Users: []modules.User{}, Total: total, Limit: limit, Offset: offset}
+		for id := offset + 1; id <= min(offset+limit, total); id++ {
+			page.Users = append(page.Users, modules.User{ID: id})
+		}
+		json.NewEncoder(w).Encode(page)
+	}))
+	defer srv.Close()
+
+	it := newClient(t, srv.URL).Users(context.Background(), 500)
+	var want int64 = 1
+	for it.Next() {
+		if id := it.User().ID; id != want {
+			t.Fatalf("user %d, want %d", id, want)
+		}
+		want++
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if want-1 != total {
+		t.Errorf("iterated %d users, want %d", want-1, total)
+	}
+	if requests.Load() != 3 {
+		t.Errorf("fetched %d pages, want 3", requests.Load())
+	}
+}
+
+func TestAPIErrorUnwrap(t *testing.T) {
+	for _, tc := range []struct {
+		status int
+		want   error
+	}{
+		{http.StatusBadRequest, apperrors.ErrValidation},
+		{http.StatusUnauthorized, client.ErrUnauthorized},
+		{http.StatusNotFound, apperrors.ErrNotFound},
+		{http.StatusConflict, apperrors.ErrConflict},
+		{http.StatusTooManyRequests, client.ErrRateLimited},
+		{http.StatusInternalServerError, apperrors.ErrInternal},
+		{http.StatusBadGateway, apperrors.ErrInternal},
+		{http.StatusServiceUnavailable, apperrors.ErrTransient},
+		{http.StatusGatewayTimeout, apperrors.ErrTimeout},
+		{http.StatusTeapot, nil},
+	} {
+		t.Run(strconv.Itoa(tc.status), func(t *testing.T) {
+			err := &client.APIError{StatusCode: tc.status}
+			if got := err.Unwrap(); got != tc.want {
+				t.Errorf("Unwrap = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAPIErrorMessage(t *testing.T) {
+	srv, _ := failing(t, http.StatusNotFound, nil)
+	_, err := newClient(t, srv.URL).GetUser(context.Background(), 1)
+	if !errors.Is(err, apperrors.ErrNotFound) {
+		t.Fatalf("err = %v, want ErrNotFound", err)
+	}
+	if want := "client: 404 Not Found: Not Found"; err.Error() != want {
+		t.Errorf("err = %q, want %q", err, want)
+	}
+}
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"practice4/practice-4/pkg/apperrors"
+)
+
+var (
+	ErrUnauthorized = errors.New("401")
+	ErrRateLimited  = errors.New("429")
+)
+
+// APIError is returned for non-2xx responses. It unwraps to the matching
+// apperrors value (or ErrUnauthorized/ErrRateLimited), so callers can use
+// errors.Is(err, apperrors.ErrNotFound) just like server-side code.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("client: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("client: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+func (e *APIError) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusNotFound:
+		return apperrors.ErrNotFound
+	case e.StatusCode == http.StatusBadRequest:
+		return apperrors.ErrValidation
+	case e.StatusCode == http.StatusConflict:
+		return apperrors.ErrConflict
+	case e.StatusCode == http.StatusGatewayTimeout:
+		return apperrors.ErrTimeout
+	case e.StatusCode == http.StatusServiceUnavailable:
+		return apperrors.ErrTransient
+	case e.StatusCode == http.StatusUnauthorized:
+		return ErrUnauthorized
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case e.StatusCode >= 500:
+		return apperrors.ErrInternal
+	}
+	return nil
+}
+
+func newAPIError(resp *http.Response) error {
+	var body struct {
+		Error string `json:"error"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	_ = json.Unmarshal(data, &body)
+	return &APIError{StatusCode: resp.StatusCode, Message: body.Error}
+}
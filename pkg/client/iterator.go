@@ -0,0 +1,61 @@
+package client
+
+import (
+	"context"
+	"practice4/practice-4/pkg/modules"
+)
+
+// UserIterator walks every page of GET /users.
+//
+//	it := c.Users(ctx, 100)
+//	for it.Next() {
+//		user := it.User()
+//	}
+//	if err := it.Err(); err != nil { ... }
+type UserIterator struct {
+	ctx      context.Context
+	client   *Client
+	pageSize int64
+	offset   int64
+	page     []modules.User
+	index    int
+	current  modules.User
+	done     bool
+	err      error
+}
+
+func (c *Client) Users(ctx context.Context, pageSize int64) *UserIterator {
+	if pageSize <= 0 {
+		pageSize = 100
+	}
+	return &UserIterator{ctx: ctx, client: c, pageSize: pageSize}
+}
+
+func (it *UserIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	if it.index >= len(it.page) {
+		if it.done {
+			return false
+		}
+		page, err := it.client.ListUsers(it.ctx, it.pageSize, it.offset)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.page, it.index = page.Users, 0
+		it.offset += int64(len(page.Users))
+		it.done = len(page.Users) < int(page.Limit) || !page.TotalIsEstimate && it.offset >= page.Total
+		if len(it.page) == 0 {
+			return false
+		}
+	}
+	it.current = it.page[it.index]
+	it.index++
+	return true
+}
+
+func (it *UserIterator) User() modules.User { return it.current }
+
+func (it *UserIterator) Err() error { return it.err }
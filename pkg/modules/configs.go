@@ -5,12 +5,102 @@ import (
 )
 
 type PostgreConfig struct {
-	Host    string
-	Port     int
-	Username string
-	Password string
-	DBName   string
-	SSLMode  string
-	ExecTimeout time.Duration
+	// URL is a full connection string (DATABASE_URL); when set it takes the
+	// place of the individual connection fields below.
+	URL         string        `yaml:"url" toml:"url"`
+	Host        string        `yaml:"host" toml:"host"`
+	Port        int           `yaml:"port" toml:"port"`
+	Username    string        `yaml:"user" toml:"user"`
+	Password    string        `yaml:"password" toml:"password"`
+	DBName      string        `yaml:"name" toml:"name"`
+	SSLMode     string        `yaml:"sslmode" toml:"sslmode"`
+	ExecTimeout time.Duration `yaml:"exec_timeout" toml:"exec_timeout"`
+
+	ApplicationName  string        `yaml:"application_name" toml:"application_name"`
+	StatementTimeout time.Duration `yaml:"statement_timeout" toml:"statement_timeout"`
+	MaxOpenConns     int           `yaml:"max_open_conns" toml:"max_open_conns"`
+	MaxIdleConns     int           `yaml:"max_idle_conns" toml:"max_idle_conns"`
+	ConnMaxLifetime  time.Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime"`
+	ConnMaxIdleTime  time.Duration `yaml:"conn_max_idle_time" toml:"conn_max_idle_time"`
+
+	Retry       RetryPolicy `yaml:"retry" toml:"retry"`
+	AutoMigrate bool        `yaml:"auto_migrate" toml:"auto_migrate"`
+
+	// OpTimeouts overrides ExecTimeout for individual repository operations,
+	// keyed by method name (e.g. "GetAll").
+	OpTimeouts map[string]time.Duration `yaml:"op_timeouts" toml:"op_timeouts"`
+
+	// ReplicaURLs are connection URLs of read replicas. User reads go to a
+	// healthy replica and fall back to the primary when none is.
+	ReplicaURLs           []string      `yaml:"replica_urls" toml:"replica_urls"`
+	ReplicaHealthInterval time.Duration `yaml:"replica_health_interval" toml:"replica_health_interval"`
+
+	// TxIsolation is the isolation level of units of work: read-committed,
+	// repeatable-read or serializable. TxMaxRetries bounds how often one is
+	// retried after a serialization failure or deadlock.
+	TxIsolation  string `yaml:"tx_isolation" toml:"tx_isolation"`
+	TxMaxRetries int    `yaml:"tx_max_retries" toml:"tx_max_retries"`
+}
+
+type RetryPolicy struct {
+	InitialInterval time.Duration `yaml:"initial_interval" toml:"initial_interval"`
+	MaxInterval     time.Duration `yaml:"max_interval" toml:"max_interval"`
+	MaxElapsed      time.Duration `yaml:"max_elapsed" toml:"max_elapsed"`
+}
+
+type ServerConfig struct {
+	Addr string `yaml:"addr" toml:"addr"`
+	// GRPCAddr is where the gRPC API listens; "off" disables it.
+	GRPCAddr        string        `yaml:"grpc_addr" toml:"grpc_addr"`
+	ReadTimeout     time.Duration `yaml:"read_timeout" toml:"read_timeout"`
+	WriteTimeout    time.Duration `yaml:"write_timeout" toml:"write_timeout"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout" toml:"idle_timeout"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+
+	// OpenAPIValidation is "off", "requests" or "all"; "all" also checks
+	// responses against the spec and is meant for tests and development.
+	OpenAPIValidation string `yaml:"openapi_validation" toml:"openapi_validation"`
+
+	// SSEHeartbeat is how often idle event streams send a comment to keep
+	// proxies from closing them.
+	SSEHeartbeat time.Duration `yaml:"sse_heartbeat" toml:"sse_heartbeat"`
 }
 
+type EventsConfig struct {
+	// Publisher is where the outbox relay sends events besides webhook
+	// subscriptions: "off", "stdout", "file" or "webhook".
+	Publisher      string        `yaml:"publisher" toml:"publisher"`
+	File           string        `yaml:"file" toml:"file"`
+	WebhookURL     string        `yaml:"webhook_url" toml:"webhook_url"`
+	WebhookTimeout time.Duration `yaml:"webhook_timeout" toml:"webhook_timeout"`
+	PollInterval   time.Duration `yaml:"poll_interval" toml:"poll_interval"`
+	BatchSize      int           `yaml:"batch_size" toml:"batch_size"`
+	// MaxAttempts is how many times an event is published before it is dead
+	// and left in the outbox for inspection.
+	MaxAttempts int `yaml:"max_attempts" toml:"max_attempts"`
+}
+
+type WebhooksConfig struct {
+	// MaxAttempts is how many times a delivery is tried before it is dead.
+	MaxAttempts  int           `yaml:"max_attempts" toml:"max_attempts"`
+	RetryInitial time.Duration `yaml:"retry_initial" toml:"retry_initial"`
+	RetryMax     time.Duration `yaml:"retry_max" toml:"retry_max"`
+	Timeout      time.Duration `yaml:"timeout" toml:"timeout"`
+	PollInterval time.Duration `yaml:"poll_interval" toml:"poll_interval"`
+	BatchSize    int           `yaml:"batch_size" toml:"batch_size"`
+}
+
+type CountConfig struct {
+	// Strategy is how user listings compute their total: "exact" counts the
+	// rows, "estimated" asks the planner and "cached" reuses exact counts
+	// for TTL.
+	Strategy string        `yaml:"strategy" toml:"strategy"`
+	TTL      time.Duration `yaml:"ttl" toml:"ttl"`
+}
+
+type CacheConfig struct {
+	// Backend is "memory" for an in-process LRU or "off".
+	Backend string        `yaml:"backend" toml:"backend"`
+	Size    int           `yaml:"size" toml:"size"`
+	TTL     time.Duration `yaml:"ttl" toml:"ttl"`
+}
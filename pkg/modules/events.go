@@ -0,0 +1,30 @@
+package modules
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	EventUserCreated  = "UserCreated"
+	EventUserUpdated  = "UserUpdated"
+	EventUserDeleted  = "UserDeleted"
+	EventUserRestored = "UserRestored"
+)
+
+// Event is a domain event recorded in the outbox alongside the change that
+// caused it. Payload is the affected user as JSON.
+type Event struct {
+	ID          int64           `json:"id"`
+	Type        string          `json:"type"`
+	AggregateID int64           `json:"aggregate_id"`
+	Payload     json.RawMessage `json:"payload"`
+	CreatedAt   time.Time       `json:"created_at"`
+
+	// Relay state, recorded after each publish attempt.
+	Attempts      int       `json:"-"`
+	LastError     string    `json:"-"`
+	NextAttemptAt time.Time `json:"-"`
+	Published     bool      `json:"-"`
+	Dead          bool      `json:"-"`
+}
@@ -1,30 +1,82 @@
 package modules
 
-import "time"
+import (
+	"encoding/xml"
+	"time"
+)
 
 type User struct {
-	ID        int64      `json:"id" db:"id"`
-	Name      string     `json:"name" db:"name"`
-	Email     string     `json:"email" db:"email"`
-	CreatedAt time.Time  `json:"created_at" db:"created_at"`
-	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+	XMLName   xml.Name   `json:"-" xml:"user" db:"-"`
+	ID        int64      `json:"id" xml:"id" db:"id"`
+	Name      string     `json:"name" xml:"name" db:"name"`
+	Email     string     `json:"email" xml:"email" db:"email"`
+	CreatedAt time.Time  `json:"created_at" xml:"created_at" db:"created_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" xml:"deleted_at,omitempty" db:"deleted_at"`
 }
 
 type UserInput struct {
-	Name  string `json:"name" example:"Alice"`
-	Email string `json:"email" example:"alice@example.com"`
+	Name  string `json:"name" xml:"name"`
+	Email string `json:"email" xml:"email"`
 }
 
 type AuditLog struct {
-	ID        int64     `db:"id"`
-	UserID    int64     `db:"user_id"`
-	Action    string    `db:"action"`
-	CreatedAt time.Time `db:"created_at"`
+	XMLName   xml.Name  `json:"-" xml:"audit_log" db:"-"`
+	ID        int64     `json:"id" xml:"id" db:"id"`
+	UserID    int64     `json:"user_id" xml:"user_id" db:"user_id"`
+	Action    string    `json:"action" xml:"action" db:"action"`
+	CreatedAt time.Time `json:"created_at" xml:"created_at" db:"created_at"`
+	// Seq orders entries by commit, unlike ID, which is taken at insert.
+	// Followers resume after it to see entries that commit out of ID order.
+	Seq int64 `json:"seq,omitempty" xml:"seq,omitempty" db:"seq"`
+}
+
+// UserSearchResult is a user matching a search. Highlights holds the name
+// and email, HTML-escaped, with the matching parts wrapped in <mark> tags.
+type UserSearchResult struct {
+	XMLName    xml.Name       `json:"-" xml:"result" db:"-"`
+	User       User           `json:"user" xml:"user"`
+	Rank       float64        `json:"rank" xml:"rank"`
+	Highlights UserHighlights `json:"highlights" xml:"highlights"`
+}
+
+type UserHighlights struct {
+	Name  string `json:"name" xml:"name"`
+	Email string `json:"email" xml:"email"`
+}
+
+type UserSearchResults struct {
+	XMLName xml.Name           `json:"-" xml:"search_results"`
+	Query   string             `json:"query" xml:"query"`
+	Results []UserSearchResult `json:"results" xml:"results>result"`
+}
+
+// UserFilter narrows user listings by case-insensitive substring matches on
+// name and email; empty fields match everything. A non-zero AfterID keeps
+// only users with greater IDs, for paging by key instead of offset.
+type UserFilter struct {
+	Name    string
+	Email   string
+	AfterID int64
+}
+
+// AuditLogFilter selects audit log entries in ascending ID order. With Tail
+// set and no AfterID, the last Limit entries are returned instead of the first.
+// AfterSeq instead selects the entries committed after the one with that Seq,
+// in commit order.
+type AuditLogFilter struct {
+	UserID   int64
+	AfterID  int64
+	AfterSeq int64
+	Limit    int64
+	Tail     bool
 }
 
 type PaginatedUsers struct {
-	Users  []User `json:"users"`
-	Total  int64  `json:"total"`
-	Limit  int64  `json:"limit"`
-	Offset int64  `json:"offset"`
+	XMLName xml.Name `json:"-" xml:"paginated_users" db:"-"`
+	Users   []User   `json:"users" xml:"users>user"`
+	Total   int64    `json:"total" xml:"total"`
+	// TotalIsEstimate is set when Total is approximate or possibly stale.
+	TotalIsEstimate bool  `json:"total_is_estimate" xml:"total_is_estimate"`
+	Limit           int64 `json:"limit" xml:"limit"`
+	Offset          int64 `json:"offset" xml:"offset"`
 }
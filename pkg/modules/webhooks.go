@@ -0,0 +1,69 @@
+package modules
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"time"
+)
+
+// EventTypes lists the event types webhooks can subscribe to.
+var EventTypes = []string{EventUserCreated, EventUserUpdated, EventUserDeleted, EventUserRestored}
+
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryDead      = "dead"
+)
+
+// WebhookSubscription receives signed POSTs of the events it lists. The
+// secret is write-only.
+type WebhookSubscription struct {
+	XMLName    xml.Name  `json:"-" xml:"webhook"`
+	ID         int64     `json:"id" xml:"id"`
+	URL        string    `json:"url" xml:"url"`
+	EventTypes []string  `json:"event_types" xml:"event_types>event_type"`
+	Secret     string    `json:"-" xml:"-"`
+	CreatedAt  time.Time `json:"created_at" xml:"created_at"`
+}
+
+type WebhookInput struct {
+	URL        string   `json:"url" xml:"url"`
+	EventTypes []string `json:"event_types" xml:"event_types>event_type"`
+	Secret     string   `json:"secret" xml:"secret"`
+}
+
+// WebhookDelivery is one event queued for, or delivered to, a subscription.
+// A pending delivery is retried at NextAttemptAt until it succeeds or runs
+// out of attempts and becomes dead.
+type WebhookDelivery struct {
+	XMLName        xml.Name        `json:"-" xml:"delivery"`
+	ID             int64           `json:"id" xml:"id"`
+	SubscriptionID int64           `json:"subscription_id" xml:"subscription_id"`
+	EventID        int64           `json:"event_id" xml:"event_id"`
+	EventType      string          `json:"event_type" xml:"event_type"`
+	Body           json.RawMessage `json:"-" xml:"-"`
+	Status         string          `json:"status" xml:"status"`
+	Attempts       int             `json:"attempts" xml:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at" xml:"next_attempt_at"`
+	LastStatusCode int             `json:"last_status_code,omitempty" xml:"last_status_code,omitempty"`
+	LastError      string          `json:"last_error,omitempty" xml:"last_error,omitempty"`
+	ReplayOf       *int64          `json:"replay_of,omitempty" xml:"replay_of,omitempty"`
+	CreatedAt      time.Time       `json:"created_at" xml:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty" xml:"delivered_at,omitempty"`
+}
+
+// DueDelivery is a delivery claimed for sending, with the URL and secret of
+// its subscription.
+type DueDelivery struct {
+	WebhookDelivery
+	URL    string
+	Secret string
+}
+
+// DeliveryFilter selects a subscription's deliveries, newest first. An empty
+// Status matches every status.
+type DeliveryFilter struct {
+	Status   string
+	BeforeID int64
+	Limit    int64
+}